@@ -1,10 +1,14 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
 	"log"
+	"net"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -35,6 +39,41 @@ type Config struct {
 	RedisPW      string `env:"REDIS_PASSWORD"`
 	RedisDB      int    `env:"REDIS_DB"`
 	RedisEnabled bool   `env:"REDIS_ENABLED"`
+
+	ReactionTypes []string `env:"REACTION_TYPES" envDefault:"like,love,haha,wow,sad,angry"`
+
+	TrashRetention    time.Duration `env:"TRASH_RETENTION" envDefault:"720h"`
+	SchedulerInterval time.Duration `env:"SCHEDULER_INTERVAL" envDefault:"30s"`
+
+	UsernameChangeCooldown time.Duration `env:"USERNAME_CHANGE_COOLDOWN" envDefault:"720h"`
+	PasswordResetTTL       time.Duration `env:"PASSWORD_RESET_TTL" envDefault:"1h"`
+	PasswordResetCooldown  time.Duration `env:"PASSWORD_RESET_COOLDOWN" envDefault:"5m"`
+	PasswordResetIPLimit   int           `env:"PASSWORD_RESET_IP_LIMIT" envDefault:"5"`
+
+	// TrustedProxies lists the addresses or CIDR ranges of the reverse proxies
+	// in front of the API. Their X-Forwarded-For headers are believed when
+	// telling clients apart, such as for the password reset limit per address.
+	// Without them every client behind a proxy shares the proxy's address.
+	TrustedProxies []string `env:"TRUSTED_PROXIES"`
+	trustedProxies []*net.IPNet
+
+	MediaBackend    string        `env:"MEDIA_BACKEND" envDefault:"local"`
+	MediaLocalDir   string        `env:"MEDIA_LOCAL_DIR" envDefault:"./uploads"`
+	MediaSigningKey string        `env:"MEDIA_SIGNING_KEY"`
+	MediaURLTTL     time.Duration `env:"MEDIA_URL_TTL" envDefault:"1h"`
+	MediaTypes      []string      `env:"MEDIA_TYPES" envDefault:"image/jpeg,image/png,image/gif,image/webp"`
+	MaxUploadSize   int64         `env:"MAX_UPLOAD_SIZE" envDefault:"10485760"`
+	OrphanRetention time.Duration `env:"MEDIA_ORPHAN_RETENTION" envDefault:"24h"`
+
+	MediaProcessInterval time.Duration `env:"MEDIA_PROCESS_INTERVAL" envDefault:"30s"`
+	ThumbnailSizes       []int         `env:"MEDIA_THUMBNAIL_SIZES" envDefault:"160,480,1080"`
+
+	S3Endpoint  string `env:"S3_ENDPOINT"`
+	S3Bucket    string `env:"S3_BUCKET"`
+	S3Region    string `env:"S3_REGION" envDefault:"us-east-1"`
+	S3AccessKey string `env:"S3_ACCESS_KEY"`
+	S3SecretKey string `env:"S3_SECRET_KEY"`
+	S3PathStyle bool   `env:"S3_PATH_STYLE"`
 }
 
 func New() (*Config, error) {
@@ -48,5 +87,80 @@ func New() (*Config, error) {
 		return nil, fmt.Errorf("error getting config: %w", err)
 	}
 
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
 	return &cfg, nil
 }
+
+// validate rejects the settings the server cannot run with.
+func (c *Config) validate() error {
+	// Media links have their own key, independent from the JWT secret.
+	if c.MediaSigningKey == "" {
+		return errors.New("MEDIA_SIGNING_KEY must be set")
+	}
+
+	// A zero TTL would make every media link expire as it is handed out.
+	if c.MediaURLTTL <= 0 {
+		return errors.New("MEDIA_URL_TTL must be positive")
+	}
+
+	if c.MaxUploadSize <= 0 {
+		return errors.New("MAX_UPLOAD_SIZE must be positive")
+	}
+
+	// Trashed posts must stay restorable for a while before they are purged.
+	if c.TrashRetention <= 0 {
+		return errors.New("TRASH_RETENTION must be positive")
+	}
+
+	// A ticker panics on a non-positive interval.
+	if c.SchedulerInterval <= 0 {
+		return errors.New("SCHEDULER_INTERVAL must be positive")
+	}
+
+	if c.MediaProcessInterval <= 0 {
+		return errors.New("MEDIA_PROCESS_INTERVAL must be positive")
+	}
+
+	for _, size := range c.ThumbnailSizes {
+		if size <= 0 {
+			return fmt.Errorf("MEDIA_THUMBNAIL_SIZES must be positive, got %d", size)
+		}
+	}
+
+	// A zero limit would silently stop every reset mail.
+	if c.PasswordResetIPLimit <= 0 {
+		return errors.New("PASSWORD_RESET_IP_LIMIT must be positive")
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("TRUSTED_PROXIES: %w", err)
+		}
+		c.trustedProxies = append(c.trustedProxies, network)
+	}
+
+	return nil
+}
+
+// IsTrustedProxy reports whether ip belongs to one of the TrustedProxies.
+func (c *Config) IsTrustedProxy(ip net.IP) bool {
+	for _, network := range c.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
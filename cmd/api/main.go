@@ -1,12 +1,15 @@
 package main
 
 import (
+	"fmt"
 	"github.com/go-redis/redis/v8"
 	"github.com/joho/godotenv"
 	"github.com/vesselchuckk/go-social/cmd/api/config"
 	"github.com/vesselchuckk/go-social/cmd/api/server"
 	"github.com/vesselchuckk/go-social/internal/auth"
+	"github.com/vesselchuckk/go-social/internal/events"
 	"github.com/vesselchuckk/go-social/internal/mails"
+	"github.com/vesselchuckk/go-social/internal/media"
 	"github.com/vesselchuckk/go-social/internal/store"
 	"github.com/vesselchuckk/go-social/internal/store/cache"
 	"go.uber.org/zap"
@@ -35,23 +38,49 @@ func main() {
 	logger.Info("database connection established")
 
 	var redisDB *redis.Client
+	var broker events.Broker = events.NewMemoryBroker()
 	if cfg.RedisEnabled {
 		redisDB = cache.NewRedisClient(
 			cfg.RedisAddr,
 			cfg.RedisPW,
 			cfg.RedisDB,
 		)
+		broker = events.NewRedisBroker(redisDB)
 		logger.Info("Redis caching is enabled")
 	}
 
 	dataStorage := store.NewStorage(db)
 
+	mediaStorage, err := newMediaStorage(cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logger.Infow("media storage ready", "backend", cfg.MediaBackend)
+
 	mailer := mails.NewMailer(cfg.MailAPI, cfg.FromEmail)
 
 	JWTauth := auth.NewJWTAuth(cfg.JWTSecret, cfg.JWTiss, cfg.JWTiss)
 
-	srv := server.NewServer(cfg, dataStorage, logger, mailer, JWTauth, redisDB)
+	srv := server.NewServer(cfg, dataStorage, logger, mailer, JWTauth, redisDB, broker, mediaStorage)
 	if err := srv.Run(); err != nil {
 		logger.Fatal(err)
 	}
 }
+
+func newMediaStorage(cfg *config.Config) (media.Storage, error) {
+	switch cfg.MediaBackend {
+	case "local":
+		return media.NewLocalStorage(cfg.MediaLocalDir)
+	case "s3":
+		return media.NewS3Storage(media.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Bucket:    cfg.S3Bucket,
+			Region:    cfg.S3Region,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown media backend %q", cfg.MediaBackend)
+	}
+}
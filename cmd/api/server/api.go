@@ -1,13 +1,16 @@
 package server
 
 import (
+	"context"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/go-redis/redis/v8"
 	"github.com/vesselchuckk/go-social/cmd/api/config"
 	"github.com/vesselchuckk/go-social/internal/auth"
+	"github.com/vesselchuckk/go-social/internal/events"
 	"github.com/vesselchuckk/go-social/internal/mails"
+	"github.com/vesselchuckk/go-social/internal/media"
 	"github.com/vesselchuckk/go-social/internal/store"
 	"github.com/vesselchuckk/go-social/internal/store/cache"
 	"go.uber.org/zap"
@@ -22,9 +25,15 @@ type Server struct {
 	Mailer  *mails.SendGridMailer
 	JWTAuth *auth.JWTAuth
 	Redis   *cache.Storage
+	Events  events.Broker
+	Media   media.Storage
+	Signer  *media.URLSigner
+
+	// mediaQueued wakes the media worker when an upload is waiting.
+	mediaQueued chan struct{}
 }
 
-func NewServer(cfg *config.Config, db *store.Store, logger *zap.SugaredLogger, mailer *mails.SendGridMailer, auth *auth.JWTAuth, rdb *redis.Client) *Server {
+func NewServer(cfg *config.Config, db *store.Store, logger *zap.SugaredLogger, mailer *mails.SendGridMailer, auth *auth.JWTAuth, rdb *redis.Client, broker events.Broker, storage media.Storage) *Server {
 	return &Server{
 		Config:  cfg,
 		Store:   db,
@@ -32,6 +41,11 @@ func NewServer(cfg *config.Config, db *store.Store, logger *zap.SugaredLogger, m
 		Mailer:  mailer,
 		JWTAuth: auth,
 		Redis:   cache.NewCacheStore(rdb),
+		Events:  broker,
+		Media:   storage,
+		Signer:  media.NewURLSigner(cfg.MediaSigningKey, cfg.MediaURLTTL),
+
+		mediaQueued: make(chan struct{}, 1),
 	}
 }
 
@@ -40,9 +54,9 @@ func (s *Server) Run() error {
 
 	router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match"},
+		ExposedHeaders:   []string{"Link", "ETag"},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
@@ -58,17 +72,118 @@ func (s *Server) Run() error {
 			router.Post("/", s.createPostHandler)
 
 			router.Route("/{postID}", func(router chi.Router) {
-				router.Use(s.postContextFetch)
+				router.With(s.trashedPostContextFetch).Post("/restore", s.checkPostOwnership("admin", s.restorePostHandler))
+
+				router.Group(func(router chi.Router) {
+					router.Use(s.postContextFetch)
+
+					router.Get("/", s.getPostByID)
+					router.Delete("/", s.checkPostOwnership("admin", s.deletePostHandler))
+					router.Patch("/", s.checkPostOwnership("moderator", s.updatePostHandler))
+
+					router.Route("/revisions", func(router chi.Router) {
+						router.Get("/", s.listRevisionsHandler)
+						router.Get("/{version}", s.getRevisionHandler)
+						router.Post("/{version}/restore", s.requireRole("moderator", s.restoreRevisionHandler))
+					})
+
+					router.Post("/repost", s.repostHandler)
+					router.Delete("/repost", s.undoRepostHandler)
+					router.Post("/quote", s.quotePostHandler)
+
+					router.Put("/bookmark", s.addBookmarkHandler)
+					router.Delete("/bookmark", s.removeBookmarkHandler)
+
+					router.Put("/reactions/{reactionType}", s.addReactionHandler)
+					router.Delete("/reactions/{reactionType}", s.removeReactionHandler)
+
+					router.Route("/comments", func(router chi.Router) {
+						router.Get("/", s.listCommentsHandler)
+						router.Post("/", s.createCommentHandler)
+
+						router.Route("/{commentID}", func(router chi.Router) {
+							router.Use(s.commentContextFetch)
 
-				router.Get("/", s.getPostByID)
-				router.Delete("/", s.checkPostOwnership("admin", s.deletePostHandler))
-				router.Patch("/", s.checkPostOwnership("moderator", s.updatePostHandler))
+							router.Get("/replies", s.getCommentRepliesHandler)
+							router.Patch("/", s.checkCommentOwnership("moderator", s.updateCommentHandler))
+							router.Delete("/", s.checkCommentOwnership("admin", s.deleteCommentHandler))
+						})
+					})
+				})
 			})
 		})
 
+		router.Route("/tags", func(router chi.Router) {
+			router.Use(s.AuthMiddleware)
+
+			router.Get("/", s.searchTagsHandler)
+			router.Get("/trending", s.trendingTagsHandler)
+			router.Get("/{tag}/posts", s.listTagPostsHandler)
+			router.Put("/{tag}/follow", s.followTagHandler)
+			router.Delete("/{tag}/follow", s.unfollowTagHandler)
+		})
+
+		router.With(s.AuthMiddleware).Get("/events", s.eventsHandler)
+
+		router.Route("/media", func(router chi.Router) {
+			router.With(s.AuthMiddleware).Post("/", s.uploadMediaHandler)
+			router.Get("/{attachmentID}", s.serveMediaHandler)
+		})
+
+		router.Route("/conversations", func(router chi.Router) {
+			router.With(s.tokenFromProtocol, s.AuthMiddleware).Get("/ws", s.conversationsSocketHandler)
+
+			router.Group(func(router chi.Router) {
+				router.Use(s.AuthMiddleware)
+
+				router.Get("/", s.listConversationsHandler)
+				router.Post("/", s.createConversationHandler)
+
+				router.Route("/{conversationID}", func(router chi.Router) {
+					router.Use(s.conversationContext)
+
+					router.Get("/", s.getConversationHandler)
+					router.Get("/messages", s.listMessagesHandler)
+					router.Post("/messages", s.sendMessageHandler)
+					router.Post("/read", s.markMessagesReadHandler)
+				})
+			})
+		})
+
+		router.Route("/notifications", func(router chi.Router) {
+			router.Use(s.AuthMiddleware)
+
+			router.Get("/", s.listNotificationsHandler)
+			router.Post("/read", s.markNotificationsReadHandler)
+		})
+
 		router.Route("/users", func(router chi.Router) {
 			router.Put("/activate/{token}", s.activateUser)
 
+			router.Route("/me", func(router chi.Router) {
+				router.Use(s.AuthMiddleware)
+
+				router.Patch("/", s.updateProfileHandler)
+				router.Get("/bookmarks", s.listBookmarksHandler)
+				router.Get("/trash", s.listTrashHandler)
+				router.Get("/drafts", s.listDraftsHandler)
+				router.Get("/tags", s.listFollowedTagsHandler)
+				router.Get("/mentions", s.listMentionsHandler)
+				router.Get("/blocks", s.listBlocksHandler)
+				router.Get("/mutes", s.listMutesHandler)
+				router.Put("/privacy", s.updatePrivacyHandler)
+				router.Put("/avatar", s.uploadAvatarHandler)
+				router.Delete("/avatar", s.deleteAvatarHandler)
+
+				router.Route("/follow-requests", func(router chi.Router) {
+					router.Get("/", s.listFollowRequestsHandler)
+					router.Post("/{requesterID}/approve", s.approveFollowRequestHandler)
+					router.Post("/{requesterID}/reject", s.rejectFollowRequestHandler)
+				})
+			})
+
+			router.With(s.AuthMiddleware).Get("/by-username/{username}", s.getUserByUsernameHandler)
+
 			router.Route("/{userID}", func(router chi.Router) {
 				router.Use(s.AuthMiddleware)
 				router.Use(s.userContext)
@@ -77,6 +192,13 @@ func (s *Server) Run() error {
 
 				router.Put("/follow", s.followUserHandler)
 				router.Put("/unfollow", s.unfollowUserHandler)
+				router.Get("/followers", s.listFollowersHandler)
+				router.Get("/following", s.listFollowingHandler)
+
+				router.Put("/block", s.blockUserHandler)
+				router.Delete("/block", s.unblockUserHandler)
+				router.Put("/mute", s.muteUserHandler)
+				router.Delete("/mute", s.unmuteUserHandler)
 			})
 
 			router.Group(func(router chi.Router) {
@@ -89,10 +211,14 @@ func (s *Server) Run() error {
 		router.Route("/auth", func(router chi.Router) {
 			router.Post("/user", s.registerHandler)
 			router.Post("/token", s.createTokenHandler)
+			router.Post("/password/forgot", s.forgotPasswordHandler)
+			router.Post("/password/reset", s.resetPasswordHandler)
 		})
 
 	})
 
+	s.startBackgroundJobs(context.Background())
+
 	srv := &http.Server{
 		Addr:    net.JoinHostPort(s.Config.ServerHost, s.Config.ServerPort),
 		Handler: middleware.Logger(router),
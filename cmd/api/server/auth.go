@@ -1,15 +1,20 @@
 package server
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/vesselchuckk/go-social/internal/events"
 	"github.com/vesselchuckk/go-social/internal/mails"
 	"github.com/vesselchuckk/go-social/internal/store"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -34,6 +39,15 @@ type CreateTokenReq struct {
 	Password string `json:"password" validate:"required,min=8,max=16"`
 }
 
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email,max=96"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=16"`
+}
+
 func (s *Server) registerHandler(w http.ResponseWriter, r *http.Request) {
 	var req RegisterRequest
 	if err := ReadJSON(w, r, &req); err != nil {
@@ -72,6 +86,7 @@ func (s *Server) registerHandler(w http.ResponseWriter, r *http.Request) {
 		default:
 			s.internalServerError(w, r, err)
 		}
+		return
 	}
 
 	userWithToken := UserWithToken{
@@ -137,6 +152,12 @@ func (s *Server) createTokenHandler(w http.ResponseWriter, r *http.Request) {
 	user, err := s.Store.Users.GetByEmail(r.Context(), req.Email)
 	if err != nil {
 		s.unauthorizedError(w, r, err)
+		return
+	}
+
+	if !user.PasswordMatches(req.Password) {
+		s.unauthorizedError(w, r, errors.New("invalid credentials"))
+		return
 	}
 
 	claims := jwt.MapClaims{
@@ -146,13 +167,166 @@ func (s *Server) createTokenHandler(w http.ResponseWriter, r *http.Request) {
 		"nbf": time.Now().Unix(),
 		"iss": s.Config.JWTiss,
 		"aud": s.Config.JWTiss,
+		"ver": user.TokenVersion,
 	}
 	token, err := s.JWTAuth.GenerateToken(claims)
 	if err != nil {
 		s.internalServerError(w, r, err)
+		return
 	}
 
 	if err := s.jsonResponse(w, http.StatusCreated, token); err != nil {
 		s.internalServerError(w, r, err)
 	}
 }
+
+// forgotPasswordHandler mails a password reset link to the owner of an active
+// account. It answers the same whether the account exists or not, so it cannot
+// be used to find out who is registered. No mail is sent while the account or
+// the client address is in its reset cooldown.
+func (s *Server) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if err := ReadJSON(w, r, &req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := s.Store.Users.GetByEmail(ctx, req.Email)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		w.WriteHeader(http.StatusAccepted)
+		return
+	case err != nil:
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	// The reset is recorded and mailed in the background, so the response time
+	// is the same for unknown addresses.
+	go s.sendPasswordReset(context.Background(), user, s.clientIP(r))
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendPasswordReset records a password reset for the user and mails its link,
+// unless the user or the client address is in its reset cooldown.
+func (s *Server) sendPasswordReset(ctx context.Context, user *store.User, ip net.IP) {
+	plainToken := uuid.New().String()
+
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+
+	err := s.Store.Users.CreatePasswordReset(ctx, user.ID, hashToken, s.Config.PasswordResetTTL, ip, s.Config.PasswordResetCooldown, s.Config.PasswordResetIPLimit)
+	switch {
+	case errors.Is(err, store.ErrResetRequestedRecently):
+		s.Logger.Infow("password reset skipped during cooldown", "user_id", user.ID, "ip", ip)
+		return
+	case err != nil:
+		s.Logger.Errorw("failed to create password reset", "user_id", user.ID, "error", err.Error())
+		return
+	}
+
+	vars := struct {
+		Username  string
+		ResetURL  string
+		ExpiresIn string
+	}{
+		Username:  user.Username,
+		ResetURL:  fmt.Sprintf("%s/reset-password/%s", s.Config.FrontendURL, plainToken),
+		ExpiresIn: humanDuration(s.Config.PasswordResetTTL),
+	}
+
+	isProdEnv := s.Config.ENV == "production"
+	if _, err := s.Mailer.Send(mails.PasswordResetTemplate, user.Username, user.Email, vars, !isProdEnv); err != nil {
+		s.Logger.Errorw("error sending password reset email", "user_id", user.ID, "error", err.Error())
+	}
+}
+
+// clientIP returns the address the request came from, or nil when it cannot be
+// parsed. Behind trusted proxies, it is the last address they forwarded for
+// that is not one of them.
+func (s *Server) clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0 && s.Config.IsTrustedProxy(ip); i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+	}
+
+	return ip
+}
+
+// humanDuration formats d for emails, such as "1 hour" or "30 minutes".
+func humanDuration(d time.Duration) string {
+	plural := func(n int64, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return plural(int64(d/time.Hour), "hour")
+	case d >= time.Minute:
+		return plural(int64(d.Round(time.Minute)/time.Minute), "minute")
+	default:
+		return plural(int64(d.Round(time.Second)/time.Second), "second")
+	}
+}
+
+// resetPasswordHandler sets a new password with a token from a reset email and
+// signs the user out of every session.
+func (s *Server) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := ReadJSON(w, r, &req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	userID, version, err := s.Store.Users.ResetPassword(ctx, req.Token, req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			s.badRequest(w, r, errors.New("the reset link is invalid or has expired"))
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := s.invalidateUser(ctx, userID); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	// Close the event streams and sockets opened with the revoked tokens.
+	revoked := events.SessionRevoked{TokenVersion: version}
+	if err := s.Events.Signal(ctx, userID, events.TypeSessionRevoked, revoked); err != nil {
+		s.Logger.Errorw("failed to signal revoked sessions", "user_id", userID, "error", err.Error())
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
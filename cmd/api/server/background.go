@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"github.com/vesselchuckk/go-social/internal/events"
+	"time"
+)
+
+const (
+	trashPurgeInterval   = time.Hour
+	publishBatchSize     = 100
+	mediaCleanupInterval = time.Hour
+	orphanBatchSize      = 100
+	eventSweepInterval   = time.Hour
+)
+
+// startBackgroundJobs launches the periodic maintenance jobs of the API process.
+func (s *Server) startBackgroundJobs(ctx context.Context) {
+	go s.runPeriodically(ctx, "trash purge", trashPurgeInterval, s.purgeTrash)
+	go s.runPeriodically(ctx, "post scheduler", s.Config.SchedulerInterval, s.publishScheduledPosts)
+	go s.runPeriodically(ctx, "media cleanup", mediaCleanupInterval, s.cleanupOrphanedMedia)
+	go s.runMediaWorker(ctx)
+
+	if sweeper, ok := s.Events.(events.Sweeper); ok {
+		go s.runPeriodically(ctx, "event stream sweep", eventSweepInterval, func(ctx context.Context) error {
+			if swept := sweeper.Sweep(); swept > 0 {
+				s.Logger.Infow("dropped idle event streams", "count", swept)
+			}
+			return nil
+		})
+	}
+}
+
+// runPeriodically calls job every interval until ctx is cancelled. Failures are
+// logged and retried on the next tick.
+func (s *Server) runPeriodically(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job(ctx); err != nil {
+			s.Logger.Errorw("background job failed", "job", name, "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) purgeTrash(ctx context.Context) error {
+	purged, err := s.Store.Posts.PurgeDeleted(ctx, time.Now().Add(-s.Config.TrashRetention))
+	if err != nil {
+		return err
+	}
+
+	if purged > 0 {
+		s.Logger.Infow("purged posts from trash", "count", purged)
+	}
+
+	return nil
+}
+
+// publishScheduledPosts publishes every scheduled post that is due, in batches.
+func (s *Server) publishScheduledPosts(ctx context.Context) error {
+	for {
+		published, err := s.Store.Posts.PublishDue(ctx, publishBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for i := range published {
+			post := &published[i]
+			s.Logger.Infow("published scheduled post", "post_id", post.ID)
+			s.postPublished(ctx, post)
+		}
+
+		if len(published) < publishBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"net/http"
+)
+
+// checkNotBlocked rejects an interaction between the current user and another
+// user when either blocked the other.
+func (s *Server) checkNotBlocked(w http.ResponseWriter, r *http.Request, otherID uuid.UUID) bool {
+	blocked, err := s.Store.Blocks.IsBlocked(r.Context(), getUserFromCtx(r).ID, otherID)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return false
+	}
+
+	if blocked {
+		s.forbiddenResponse(w, r)
+		return false
+	}
+
+	return true
+}
+
+// BLOCKS HANDLER
+
+func (s *Server) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	s.relateToUser(w, r, s.Store.Blocks.Block)
+}
+
+func (s *Server) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	s.relateToUser(w, r, s.Store.Blocks.Unblock)
+}
+
+func (s *Server) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	s.relateToUser(w, r, s.Store.Mutes.Mute)
+}
+
+func (s *Server) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	s.relateToUser(w, r, s.Store.Mutes.Unmute)
+}
+
+func (s *Server) listBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	s.listRelated(w, r, s.Store.Blocks.List)
+}
+
+func (s *Server) listMutesHandler(w http.ResponseWriter, r *http.Request) {
+	s.listRelated(w, r, s.Store.Mutes.List)
+}
+
+// relateToUser applies a block or mute change from the current user to the
+// user in the URL.
+func (s *Server) relateToUser(w http.ResponseWriter, r *http.Request, apply func(ctx context.Context, userID, otherID uuid.UUID) error) {
+	user := getUserFromCtx(r)
+	target := getTargetUserFromCtx(r)
+
+	if user.ID == target.ID {
+		s.badRequest(w, r, errors.New("you cannot block or mute yourself"))
+		return
+	}
+
+	if err := apply(r.Context(), user.ID, target.ID); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listRelated(w http.ResponseWriter, r *http.Request, list func(ctx context.Context, user *store.User, fq store.PaginatedQuery) ([]store.RelatedUser, store.Page, error)) {
+	fq := store.PaginatedQuery{
+		Limit: 20,
+		Sort:  "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	users, page, err := list(r.Context(), getUserFromCtx(r), fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			s.badRequest(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := s.jsonPageResponse(w, r, http.StatusOK, users, page); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
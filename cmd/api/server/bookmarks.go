@@ -0,0 +1,72 @@
+package server
+
+import (
+	"errors"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"net/http"
+)
+
+// BOOKMARKS HANDLER
+
+func (s *Server) addBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
+
+	if err := s.Store.Bookmarks.Add(r.Context(), user.ID, post.ID); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) removeBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
+
+	if err := s.Store.Bookmarks.Remove(r.Context(), user.ID, post.ID); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedQuery{
+		Limit: 20,
+		Sort:  "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	bookmarks, page, err := s.Store.Bookmarks.List(r.Context(), user, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			s.badRequest(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	for i := range bookmarks {
+		s.signPostMedia(&bookmarks[i].Post)
+	}
+
+	if err := s.jsonPageResponse(w, r, http.StatusOK, bookmarks, page); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
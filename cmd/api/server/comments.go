@@ -0,0 +1,219 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"net/http"
+	"strconv"
+)
+
+// commentPreviewSize is how many of the latest comments are embedded in a post response.
+const commentPreviewSize = 3
+
+// COMMENTS PAYLOAD
+type CreateCommentRequest struct {
+	Content  string `json:"content" validate:"required,max=1000"`
+	ParentID *int64 `json:"parent_id" validate:"omitempty,gte=1"`
+}
+
+type UpdateCommentRequest struct {
+	Content *string `json:"content" validate:"omitempty,max=1000"`
+	Version *int    `json:"version"`
+}
+
+// COMMENTS HANDLER
+
+func (s *Server) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateCommentRequest
+	if err := ReadJSON(w, r, &req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	post := getPostFromCtx(r)
+
+	ctx := r.Context()
+
+	var parent *store.Comment
+	if req.ParentID != nil {
+		var err error
+		parent, err = s.Store.Comments.GetByID(ctx, *req.ParentID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		if parent == nil || parent.PostID != post.ID {
+			s.badRequest(w, r, errors.New("parent comment does not belong to this post"))
+			return
+		}
+	}
+
+	if !s.checkNotBlocked(w, r, post.UserID) {
+		return
+	}
+
+	if parent != nil && parent.UserID != post.UserID && !s.checkNotBlocked(w, r, parent.UserID) {
+		return
+	}
+
+	comment := &store.Comment{
+		PostID:   post.ID,
+		ParentID: req.ParentID,
+		UserID:   user.ID,
+		Content:  req.Content,
+		User: store.User{
+			ID:       user.ID,
+			Username: user.Username,
+		},
+	}
+
+	if err := s.Store.Comments.Create(ctx, comment); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	s.notify(ctx, &store.Notification{
+		UserID:    post.UserID,
+		ActorID:   user.ID,
+		Type:      store.NotificationComment,
+		PostID:    &post.ID,
+		CommentID: &comment.ID,
+	})
+
+	if parent != nil && parent.UserID != post.UserID {
+		s.notify(ctx, &store.Notification{
+			UserID:    parent.UserID,
+			ActorID:   user.ID,
+			Type:      store.NotificationReply,
+			PostID:    &post.ID,
+			CommentID: &comment.ID,
+		})
+	}
+
+	s.recordCommentMentions(ctx, comment)
+
+	if err := s.jsonResponse(w, http.StatusCreated, comment); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) listCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedQuery{
+		Limit: 20,
+		Sort:  "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	post := getPostFromCtx(r)
+
+	comments, page, err := s.Store.Comments.ListByPostID(r.Context(), post.ID, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			s.badRequest(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := s.jsonPageResponse(w, r, http.StatusOK, comments, page); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) getCommentRepliesHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	depth := store.MaxCommentDepth
+	if rawDepth := r.URL.Query().Get("depth"); rawDepth != "" {
+		d, err := strconv.Atoi(rawDepth)
+		if err != nil || d < 1 || d > store.MaxCommentDepth {
+			s.badRequest(w, r, fmt.Errorf("depth must be between 1 and %d", store.MaxCommentDepth))
+			return
+		}
+		depth = d
+	}
+
+	replies, err := s.Store.Comments.GetReplies(r.Context(), comment, depth)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if err := s.jsonResponse(w, http.StatusOK, replies); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	var req UpdateCommentRequest
+	if err := ReadJSON(w, r, &req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if req.Version != nil && *req.Version != comment.Version {
+		s.conflictResponse(w, r, fmt.Errorf("%w: current version is %d", store.ErrEditConflict, comment.Version))
+		return
+	}
+
+	if req.Content != nil {
+		comment.Content = *req.Content
+	}
+
+	if err := s.Store.Comments.Update(r.Context(), comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrEditConflict):
+			s.conflictResponse(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := s.jsonResponse(w, http.StatusOK, comment); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	if err := s.Store.Comments.Delete(r.Context(), comment); err != nil {
+		switch {
+		case errors.Is(err, store.ErrEditConflict):
+			s.conflictResponse(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
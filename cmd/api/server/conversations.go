@@ -0,0 +1,382 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/vesselchuckk/go-social/internal/events"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+type conversationKey string
+
+var errConversationBlocked = errors.New("you cannot message a user you blocked or were blocked by")
+
+const conversationCtx conversationKey = "conversation"
+
+// CONVERSATIONS PAYLOAD
+type CreateConversationRequest struct {
+	MemberIDs []uuid.UUID `json:"member_ids" validate:"required,min=1,max=9"`
+	Title     *string     `json:"title" validate:"omitempty,max=100"`
+}
+
+type SendMessageRequest struct {
+	Content string `json:"content" validate:"required,max=2000"`
+}
+
+type MarkMessagesReadRequest struct {
+	MessageID int64 `json:"message_id" validate:"required,gte=1"`
+}
+
+// ReadReceipt tells the members of a conversation how far a member has read.
+type ReadReceipt struct {
+	ConversationID int64     `json:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	MessageID      int64     `json:"message_id"`
+}
+
+// TypingIndicator tells the members of a conversation that a member is typing.
+type TypingIndicator struct {
+	ConversationID int64     `json:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	Username       string    `json:"username"`
+}
+
+// CONVERSATIONS HANDLER
+
+func (s *Server) createConversationHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateConversationRequest
+	if err := ReadJSON(w, r, &req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	var memberIDs []uuid.UUID
+	for _, id := range req.MemberIDs {
+		if id != user.ID && !slices.Contains(memberIDs, id) {
+			memberIDs = append(memberIDs, id)
+		}
+	}
+
+	if len(memberIDs) == 0 {
+		s.badRequest(w, r, errors.New("a conversation needs at least one other member"))
+		return
+	}
+
+	for _, id := range memberIDs {
+		if !s.checkNotBlocked(w, r, id) {
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	// One-to-one conversations are unique per pair of users; starting one again
+	// returns the existing conversation.
+	isGroup := len(memberIDs) > 1 || req.Title != nil
+	if !isGroup && s.respondDirectConversation(w, r, memberIDs[0]) {
+		return
+	}
+
+	conv := &store.Conversation{
+		CreatedBy: user.ID,
+		IsGroup:   isGroup,
+		Title:     req.Title,
+	}
+
+	if err := s.Store.Conversations.Create(ctx, conv, memberIDs); err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidMembers):
+			s.badRequest(w, r, err)
+		case errors.Is(err, store.ErrDirectAlreadyStarted):
+			// Another request started it in the meantime.
+			if !s.respondDirectConversation(w, r, memberIDs[0]) {
+				s.internalServerError(w, r, err)
+			}
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	conv, err := s.Store.Conversations.GetForMember(ctx, conv.ID, user.ID)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if err := s.jsonResponse(w, http.StatusCreated, conv); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+// respondDirectConversation responds with the one-to-one conversation between
+// the user and otherID, and reports whether a response was written.
+func (s *Server) respondDirectConversation(w http.ResponseWriter, r *http.Request, otherID uuid.UUID) bool {
+	conv, err := s.Store.Conversations.FindDirect(r.Context(), getUserFromCtx(r).ID, otherID)
+	switch {
+	case err == nil:
+		if err := s.jsonResponse(w, http.StatusOK, conv); err != nil {
+			s.internalServerError(w, r, err)
+		}
+		return true
+	case errors.Is(err, store.ErrNotFound):
+		return false
+	default:
+		s.internalServerError(w, r, err)
+		return true
+	}
+}
+
+func (s *Server) listConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedQuery{
+		Limit: 20,
+		Sort:  "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	convs, page, err := s.Store.Conversations.List(r.Context(), user, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			s.badRequest(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := s.jsonPageResponse(w, r, http.StatusOK, convs, page); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) getConversationHandler(w http.ResponseWriter, r *http.Request) {
+	conv := getConversationFromCtx(r)
+
+	if err := s.jsonResponse(w, http.StatusOK, conv); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) listMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedQuery{
+		Limit: 20,
+		Sort:  "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	conv := getConversationFromCtx(r)
+
+	messages, page, err := s.Store.Conversations.ListMessages(r.Context(), conv.ID, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			s.badRequest(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := s.jsonPageResponse(w, r, http.StatusOK, messages, page); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) sendMessageHandler(w http.ResponseWriter, r *http.Request) {
+	var req SendMessageRequest
+	if err := ReadJSON(w, r, &req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	conv := getConversationFromCtx(r)
+	user := getUserFromCtx(r)
+
+	msg, err := s.sendMessage(r.Context(), conv, user, req.Content)
+	if err != nil {
+		switch {
+		case errors.Is(err, errConversationBlocked):
+			s.forbiddenResponse(w, r)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := s.jsonResponse(w, http.StatusCreated, msg); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) markMessagesReadHandler(w http.ResponseWriter, r *http.Request) {
+	var req MarkMessagesReadRequest
+	if err := ReadJSON(w, r, &req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	conv := getConversationFromCtx(r)
+	user := getUserFromCtx(r)
+
+	if err := s.markConversationRead(r.Context(), conv, user, req.MessageID); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sendMessage stores a message and delivers it live to every member of the
+// conversation, the sender's other sessions included. One-to-one conversations
+// are closed once either user blocked the other.
+func (s *Server) sendMessage(ctx context.Context, conv *store.Conversation, sender *store.User, content string) (*store.Message, error) {
+	if !conv.IsGroup {
+		for _, member := range conv.Members {
+			if member.UserID == sender.ID {
+				continue
+			}
+
+			blocked, err := s.Store.Blocks.IsBlocked(ctx, sender.ID, member.UserID)
+			if err != nil {
+				return nil, err
+			}
+
+			if blocked {
+				return nil, errConversationBlocked
+			}
+		}
+	}
+
+	msg := &store.Message{
+		ConversationID: conv.ID,
+		SenderID:       sender.ID,
+		Content:        content,
+	}
+
+	if err := s.Store.Conversations.CreateMessage(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	for _, member := range conv.Members {
+		s.pushEvent(ctx, member.UserID, events.TypeMessage, msg)
+	}
+
+	return msg, nil
+}
+
+// markConversationRead moves the read marker of the user and sends a read
+// receipt to the other members when it moved.
+func (s *Server) markConversationRead(ctx context.Context, conv *store.Conversation, user *store.User, messageID int64) error {
+	moved, err := s.Store.Conversations.MarkRead(ctx, conv.ID, user.ID, messageID)
+	if err != nil || !moved {
+		return err
+	}
+
+	receipt := ReadReceipt{
+		ConversationID: conv.ID,
+		UserID:         user.ID,
+		MessageID:      messageID,
+	}
+
+	for _, member := range conv.Members {
+		if member.UserID != user.ID {
+			s.pushEvent(ctx, member.UserID, events.TypeRead, receipt)
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) signalTyping(ctx context.Context, conv *store.Conversation, user *store.User) {
+	indicator := TypingIndicator{
+		ConversationID: conv.ID,
+		UserID:         user.ID,
+		Username:       user.Username,
+	}
+
+	for _, member := range conv.Members {
+		if member.UserID == user.ID {
+			continue
+		}
+
+		if err := s.Events.Signal(ctx, member.UserID, events.TypeTyping, indicator); err != nil {
+			s.Logger.Errorw("failed to signal typing", "conversation_id", conv.ID, "error", err.Error())
+		}
+	}
+}
+
+func (s *Server) conversationContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "conversationID"), 10, 64)
+		if err != nil {
+			s.badRequest(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		conv, err := s.Store.Conversations.GetForMember(ctx, id, getUserFromCtx(r).ID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				s.notFoundError(w, r, fmt.Errorf("conversation %d: %w", id, err))
+				return
+			}
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		ctx = context.WithValue(ctx, conversationCtx, conv)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getConversationFromCtx(r *http.Request) *store.Conversation {
+	conv, _ := r.Context().Value(conversationCtx).(*store.Conversation)
+	return conv
+}
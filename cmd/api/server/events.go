@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/vesselchuckk/go-social/internal/events"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// eventsHeartbeat keeps idle event streams from being closed by proxies.
+const eventsHeartbeat = 25 * time.Second
+
+// postPublished runs the side effects of a post going live: its mentions are
+// recorded and it is pushed to the live feeds of its audience.
+func (s *Server) postPublished(ctx context.Context, post *store.Post) {
+	if post.Status != store.PostStatusPublished {
+		return
+	}
+
+	s.recordMentions(ctx, post.UserID, post.ID, nil, post.Content)
+
+	// The fan-out outlives the request that published the post.
+	go s.pushFeedItem(context.Background(), *post)
+}
+
+func (s *Server) pushFeedItem(ctx context.Context, post store.Post) {
+	audience, err := s.Store.Posts.FeedAudience(ctx, &post)
+	if err != nil {
+		s.Logger.Errorw("failed to load feed audience", "post_id", post.ID, "error", err.Error())
+		return
+	}
+
+	for _, userID := range audience {
+		s.pushEvent(ctx, userID, events.TypeFeed, post)
+	}
+}
+
+// pushEvent publishes an event to the live streams of a user. Failures are
+// logged; clients catch up through the regular listings.
+func (s *Server) pushEvent(ctx context.Context, userID uuid.UUID, eventType string, data any) {
+	if err := s.Events.Publish(ctx, userID, eventType, data); err != nil {
+		s.Logger.Errorw("failed to publish event", "type", eventType, "user_id", userID, "error", err.Error())
+	}
+}
+
+// revokesSession reports whether the event revokes the token the user
+// authenticated with.
+func revokesSession(event events.Event, user *store.User) bool {
+	if event.Type != events.TypeSessionRevoked {
+		return false
+	}
+
+	var revoked events.SessionRevoked
+	if err := json.Unmarshal(event.Data, &revoked); err != nil {
+		return true
+	}
+
+	return user.TokenVersion < revoked.TokenVersion
+}
+
+// EVENTS HANDLER
+
+// eventsHandler streams the notifications and feed items of the current user as
+// Server-Sent Events. Clients resume with the Last-Event-ID header.
+func (s *Server) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.internalServerError(w, r, errors.New("streaming is not supported"))
+		return
+	}
+
+	var lastEventID uint64
+	rawLastID := r.Header.Get("Last-Event-ID")
+	if rawLastID == "" {
+		rawLastID = r.URL.Query().Get("last_event_id")
+	}
+	if rawLastID != "" {
+		id, err := strconv.ParseUint(rawLastID, 10, 64)
+		if err != nil {
+			s.badRequest(w, r, err)
+			return
+		}
+		lastEventID = id
+	}
+
+	user := getUserFromCtx(r)
+
+	// Like sockets, streams end when the token they were opened with expires.
+	ctx, cancel := context.WithDeadline(r.Context(), getTokenExpiryFromCtx(r))
+	defer cancel()
+
+	stream, err := s.Events.Subscribe(ctx, user.ID, lastEventID)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-stream:
+			if !ok {
+				return
+			}
+
+			if revokesSession(event, user) {
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data)
+				flusher.Flush()
+				return
+			}
+
+			if event.ID != 0 {
+				if _, err := fmt.Fprintf(w, "id: %d\n", event.ID); err != nil {
+					return
+				}
+			}
+
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data); err != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
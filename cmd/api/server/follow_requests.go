@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"net/http"
+)
+
+// FOLLOW REQUESTS PAYLOAD
+type UpdatePrivacyRequest struct {
+	IsPrivate *bool `json:"is_private" validate:"required"`
+}
+
+// requestFollow asks a private account for permission to follow it.
+func (s *Server) requestFollow(w http.ResponseWriter, r *http.Request, requester, target *store.User) {
+	ctx := r.Context()
+
+	following, err := s.Store.Followers.IsFollowing(ctx, requester.ID, target.ID)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if following {
+		s.conflictResponse(w, r, store.ErrAlreadyFollowing)
+		return
+	}
+
+	if err := s.Store.FollowRequests.Create(ctx, requester.ID, target.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrAlreadyRequested):
+			s.conflictResponse(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	s.notify(ctx, &store.Notification{
+		UserID:  target.ID,
+		ActorID: requester.ID,
+		Type:    store.NotificationFollowRequest,
+	})
+
+	if err := s.jsonResponse(w, http.StatusAccepted, map[string]string{"status": "requested"}); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+// canViewContent reports whether the viewer may see what a user shares with
+// their followers: public accounts are open to everyone, private ones to their
+// followers only.
+func (s *Server) canViewContent(ctx context.Context, viewer, owner *store.User) (bool, error) {
+	if !owner.IsPrivate || viewer.ID == owner.ID {
+		return true, nil
+	}
+
+	return s.Store.Followers.IsFollowing(ctx, viewer.ID, owner.ID)
+}
+
+// FOLLOW REQUESTS HANDLER
+
+func (s *Server) listFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedQuery{
+		Limit: 20,
+		Sort:  "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	requests, page, err := s.Store.FollowRequests.ListPending(r.Context(), user, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			s.badRequest(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := s.jsonPageResponse(w, r, http.StatusOK, requests, page); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	requesterID, err := uuid.Parse(chi.URLParam(r, "requesterID"))
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	ctx := r.Context()
+
+	if err := s.Store.FollowRequests.Approve(ctx, requesterID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			s.notFoundError(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	s.notify(ctx, &store.Notification{
+		UserID:  requesterID,
+		ActorID: user.ID,
+		Type:    store.NotificationFollowAccepted,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	requesterID, err := uuid.Parse(chi.URLParam(r, "requesterID"))
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := s.Store.FollowRequests.Delete(r.Context(), requesterID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			s.notFoundError(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) updatePrivacyHandler(w http.ResponseWriter, r *http.Request) {
+	var req UpdatePrivacyRequest
+	if err := ReadJSON(w, r, &req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	ctx := r.Context()
+
+	if err := s.Store.Users.SetPrivacy(ctx, user.ID, *req.IsPrivate); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if err := s.invalidateUser(ctx, user.ID); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	user.IsPrivate = *req.IsPrivate
+
+	if err := s.jsonResponse(w, http.StatusOK, user); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
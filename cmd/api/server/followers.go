@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"net/http"
+)
+
+// UserProfile is a user as shown on their profile, with their follow graph
+// relative to the current user.
+type UserProfile struct {
+	*store.User
+	store.FollowStats
+}
+
+// FOLLOWERS HANDLER
+
+func (s *Server) listFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	s.listFollows(w, r, s.Store.Followers.ListFollowers)
+}
+
+func (s *Server) listFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	s.listFollows(w, r, s.Store.Followers.ListFollowing)
+}
+
+func (s *Server) listFollows(w http.ResponseWriter, r *http.Request, list func(ctx context.Context, userID, viewerID uuid.UUID, fq store.PaginatedQuery) ([]store.FollowEntry, store.Page, error)) {
+	fq := store.PaginatedQuery{
+		Limit: 20,
+		Sort:  "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	target := getTargetUserFromCtx(r)
+	viewer := getUserFromCtx(r)
+
+	ctx := r.Context()
+
+	allowed, err := s.canViewContent(ctx, viewer, target)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if !allowed {
+		s.forbiddenResponse(w, r)
+		return
+	}
+
+	entries, page, err := list(ctx, target.ID, viewer.ID, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			s.badRequest(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := s.jsonPageResponse(w, r, http.StatusOK, entries, page); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
@@ -2,31 +2,29 @@ package server
 
 import (
 	"errors"
-	"github.com/go-chi/chi/v5"
-	"github.com/google/uuid"
-	"github.com/lib/pq"
 	"github.com/vesselchuckk/go-social/internal/store"
-	"log"
 	"net/http"
-	"strconv"
+	"time"
 )
 
 // POSTS PAYLOAD
 type CreatePostRequest struct {
-	Title   string         `json:"title" validate:"required,max=100"`
-	Content string         `json:"content" validate:"required,max=1000"`
-	Tags    pq.StringArray `json:"tags"`
+	Title     string     `json:"title" validate:"required,max=100"`
+	Content   string     `json:"content" validate:"required,max=1000"`
+	Tags      []string   `json:"tags"`
+	Status    string     `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt *time.Time `json:"publish_at"`
+
+	AttachmentIDs []int64 `json:"attachment_ids" validate:"max=4,unique,dive,gt=0"`
 }
 
 // USER PAYLOAD
 type UpdatePostRequest struct {
-	Title   *string `json:"title" validate:"omitempty,max=100"`
-	Content *string `json:"content" validate:"omitempty,max=1000"`
-}
-
-type FollowRequest struct {
-	UserID     uuid.UUID `json:"user_id"`
-	FollowerID uuid.UUID `json:"follower_id"`
+	Title     *string    `json:"title" validate:"omitempty,max=100"`
+	Content   *string    `json:"content" validate:"omitempty,max=1000"`
+	Tags      *[]string  `json:"tags"`
+	Status    *string    `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt *time.Time `json:"publish_at"`
 }
 
 // HEALTH HANDLER
@@ -57,23 +55,53 @@ func (s *Server) createPostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tags, err := store.NormalizeTags(req.Tags)
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
 	user := getUserFromCtx(r)
 
 	post := &store.Post{
-		Title:   req.Title,
-		Content: req.Content,
-		UserID:  user.ID,
+		Title:     req.Title,
+		Content:   req.Content,
+		Tags:      tags,
+		UserID:    user.ID,
+		Status:    req.Status,
+		PublishAt: req.PublishAt,
+	}
+
+	for _, id := range req.AttachmentIDs {
+		post.Attachments = append(post.Attachments, store.Attachment{ID: id})
+	}
+
+	if post.Status == "" {
+		post.Status = store.PostStatusPublished
+	}
+
+	if err := checkPublishState(post); err != nil {
+		s.badRequest(w, r, err)
+		return
 	}
 
 	ctx := r.Context()
 
-	err := s.Store.Posts.CreatePost(ctx, post)
-	if err != nil {
-		s.internalServerError(w, r, err)
-		log.Fatalf("error creating post: %v", err)
+	if err := s.Store.Posts.CreatePost(ctx, post); err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidAttachments):
+			s.badRequest(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
 		return
 	}
 
+	s.postPublished(ctx, post)
+	s.signPostMedia(post)
+
+	setPostETag(w, post)
+
 	if err := s.jsonResponse(w, http.StatusCreated, post); err != nil {
 		s.badRequest(w, r, err)
 		return
@@ -83,15 +111,55 @@ func (s *Server) createPostHandler(w http.ResponseWriter, r *http.Request) {
 func (s *Server) getPostByID(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
 
-	comments, err := s.Store.Comments.GetByPostID(r.Context(), post.ID)
+	ctx := r.Context()
+
+	preview := store.PaginatedQuery{
+		Limit: commentPreviewSize,
+		Sort:  "desc",
+	}
+
+	comments, _, err := s.Store.Comments.ListByPostID(ctx, post.ID, preview)
 	if err != nil {
 		s.internalServerError(w, r, err)
 		return
 	}
 
+	commentCount, err := s.Store.Comments.CountByPostID(ctx, post.ID)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	reactions, myReactions, err := s.Store.Reactions.GetSummary(ctx, post.ID, getUserFromCtx(r).ID)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if err := s.Store.Posts.AttachOriginals(ctx, getUserFromCtx(r).ID, post); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if err := s.withMedia(ctx, post); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
 	post.Comments = comments
+	post.Reactions = reactions
+	post.MyReactions = myReactions
+	post.Reacted = len(myReactions) > 0
 
-	if err := s.jsonResponse(w, http.StatusOK, post); err != nil {
+	resp := store.PostMetadata{
+		Post:          *post,
+		CommentCount:  commentCount,
+		ReactionCount: reactions.Total(),
+	}
+
+	setPostETag(w, post)
+
+	if err := s.jsonResponse(w, http.StatusOK, resp); err != nil {
 		s.internalServerError(w, r, err)
 		return
 	}
@@ -100,6 +168,10 @@ func (s *Server) getPostByID(w http.ResponseWriter, r *http.Request) {
 func (s *Server) updatePostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
 
+	if !s.checkIfMatch(w, r, post) {
+		return
+	}
+
 	var req UpdatePostRequest
 	if err := ReadJSON(w, r, &req); err != nil {
 		s.badRequest(w, r, err)
@@ -117,29 +189,91 @@ func (s *Server) updatePostHandler(w http.ResponseWriter, r *http.Request) {
 	if req.Content != nil {
 		post.Content = *req.Content
 	}
+	if req.Tags != nil {
+		tags, err := store.NormalizeTags(*req.Tags)
+		if err != nil {
+			s.badRequest(w, r, err)
+			return
+		}
+		post.Tags = tags
+	}
+	wasPublished := post.Status == store.PostStatusPublished
 
-	if err := s.Store.Posts.Update(r.Context(), post); err != nil {
+	if req.Status != nil {
+		if post.Status == store.PostStatusPublished && *req.Status != store.PostStatusPublished {
+			s.badRequest(w, r, errors.New("a published post cannot be turned back into a draft"))
+			return
+		}
+		post.Status = *req.Status
+	}
+	if req.PublishAt != nil {
+		post.PublishAt = req.PublishAt
+	}
+
+	if err := checkPublishState(post); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := s.Store.Posts.Update(r.Context(), post, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrEditConflict):
+			s.conflictResponse(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if !wasPublished {
+		s.postPublished(r.Context(), post)
+	}
+
+	if err := s.withMedia(r.Context(), post); err != nil {
 		s.internalServerError(w, r, err)
 		return
 	}
 
+	setPostETag(w, post)
+
 	if err := s.jsonResponse(w, http.StatusOK, post); err != nil {
 		s.internalServerError(w, r, err)
 	}
 }
 
+// checkPublishState makes sure scheduled posts carry a future publish time and
+// drops the publish time from the other states.
+func checkPublishState(post *store.Post) error {
+	if post.Status != store.PostStatusScheduled {
+		post.PublishAt = nil
+		return nil
+	}
+
+	if post.PublishAt == nil || !post.PublishAt.After(time.Now()) {
+		return errors.New("scheduled posts need a publish_at in the future")
+	}
+
+	return nil
+}
+
 func (s *Server) deletePostHandler(w http.ResponseWriter, r *http.Request) {
-	idParam := chi.URLParam(r, "postID")
-	id, err := strconv.ParseInt(idParam, 10, 64)
-	if err != nil {
-		s.internalServerError(w, r, err)
+	post := getPostFromCtx(r)
+
+	if !s.checkIfMatch(w, r, post) {
 		return
 	}
 
 	ctx := r.Context()
 
-	if err := s.Store.Posts.Delete(ctx, id); err != nil {
-		s.internalServerError(w, r, err)
+	if err := s.Store.Posts.Delete(ctx, post.ID, post.Version); err != nil {
+		switch {
+		case errors.Is(err, store.ErrEditConflict):
+			s.conflictResponse(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
 		return
 	}
 
@@ -147,83 +281,89 @@ func (s *Server) deletePostHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) getUserHandler(w http.ResponseWriter, r *http.Request) {
-	rawUserID := chi.URLParam(r, "userID")
-	if rawUserID == "" {
-		s.badRequest(w, r, errors.New("userID is required"))
-		return
-	}
-
-	userID, err := uuid.Parse(rawUserID)
-	if err != nil {
-		s.badRequest(w, r, err)
-		return
-	}
+	user := getTargetUserFromCtx(r)
 
-	user, err := s.Redis.Users.Get(r.Context(), userID)
+	stats, err := s.Store.Followers.Stats(r.Context(), user.ID, getUserFromCtx(r).ID)
 	if err != nil {
 		s.internalServerError(w, r, err)
 		return
 	}
 
-	if user == nil {
-		user, err = s.Store.Users.GetByID(r.Context(), userID)
-		if err != nil {
-			s.notFoundError(w, r, err)
-			return
-		}
+	s.signAvatar(user)
+
+	profile := UserProfile{
+		User:        user,
+		FollowStats: *stats,
 	}
 
-	if err := s.jsonResponse(w, http.StatusOK, user); err != nil {
+	if err := s.jsonResponse(w, http.StatusOK, profile); err != nil {
 		s.internalServerError(w, r, err)
 	}
 }
 
 func (s *Server) followUserHandler(w http.ResponseWriter, r *http.Request) {
-	followedUser := getUserFromCtx(r)
-	followedID, err := uuid.Parse(chi.URLParam(r, "userID"))
-	if err != nil {
-		s.badRequest(w, r, err)
+	follower := getUserFromCtx(r)
+	followed := getTargetUserFromCtx(r)
+
+	if follower.ID == followed.ID {
+		s.badRequest(w, r, errors.New("you cannot follow yourself"))
+		return
+	}
+
+	if !s.checkNotBlocked(w, r, followed.ID) {
 		return
 	}
 
 	ctx := r.Context()
 
-	if err := s.Store.Followers.Follow(ctx, followedUser.ID, followedID); err != nil {
-		s.internalServerError(w, r, err)
+	if followed.IsPrivate {
+		s.requestFollow(w, r, follower, followed)
 		return
 	}
 
-	if err := s.jsonResponse(w, http.StatusNoContent, nil); err != nil {
-		s.internalServerError(w, r, err)
+	if err := s.Store.Followers.Follow(ctx, follower.ID, followed.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrAlreadyFollowing):
+			s.conflictResponse(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
 	}
+
+	s.notify(ctx, &store.Notification{
+		UserID:  followed.ID,
+		ActorID: follower.ID,
+		Type:    store.NotificationFollow,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (s *Server) unfollowUserHandler(w http.ResponseWriter, r *http.Request) {
-	unfollowedUser := getUserFromCtx(r)
-
-	var req FollowRequest
-	if err := ReadJSON(w, r, &req); err != nil {
-		s.badRequest(w, r, err)
-		return
-	}
+	follower := getUserFromCtx(r)
+	unfollowed := getTargetUserFromCtx(r)
 
 	ctx := r.Context()
 
-	if err := s.Store.Followers.Unfollow(ctx, unfollowedUser.ID, req.UserID); err != nil {
+	if err := s.Store.Followers.Unfollow(ctx, follower.ID, unfollowed.ID); err != nil {
 		s.internalServerError(w, r, err)
 		return
 	}
 
-	if err := s.jsonResponse(w, http.StatusNoContent, nil); err != nil {
+	// Unfollowing a private account also withdraws a pending request.
+	if err := s.Store.FollowRequests.Delete(ctx, follower.ID, unfollowed.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
 		s.internalServerError(w, r, err)
+		return
 	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (s *Server) getUserFeed(w http.ResponseWriter, r *http.Request) {
 	fq := store.PaginatedQuery{
-		Limit:  20,
-		Offset: 0,
-		Sort:   "desc",
+		Limit: 20,
+		Sort:  "desc",
 	}
 
 	fq, err := fq.Parse(r)
@@ -241,12 +381,22 @@ func (s *Server) getUserFeed(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 
-	feed, err := s.Store.Posts.GetUserFeed(ctx, user, fq)
+	feed, page, err := s.Store.Posts.GetUserFeed(ctx, user, fq)
 	if err != nil {
-		s.internalServerError(w, r, err)
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			s.badRequest(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	for i := range feed {
+		s.signPostMedia(&feed[i].Post)
 	}
 
-	if err := s.jsonResponse(w, http.StatusOK, feed); err != nil {
+	if err := s.jsonPageResponse(w, r, http.StatusOK, feed, page); err != nil {
 		s.internalServerError(w, r, err)
 	}
 }
@@ -3,19 +3,28 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/vesselchuckk/go-social/internal/store"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type postKey string
 type userKey string
+type commentKey string
+type tokenKey string
 
 const postCtx postKey = "post"
 const userCtx userKey = "user"
+const targetUserCtx userKey = "targetUser"
+const commentCtx commentKey = "comment"
+const tokenExpiryCtx tokenKey = "tokenExpiry"
 
 var Validate *validator.Validate
 
@@ -38,6 +47,43 @@ func (s *Server) jsonResponse(w http.ResponseWriter, status int, data any) error
 	return WriteJSON(w, status, &envelope{Data: data})
 }
 
+func (s *Server) jsonPageResponse(w http.ResponseWriter, r *http.Request, status int, data any, page store.Page) error {
+	type envelope struct {
+		Data any `json:"data"`
+		store.Page
+	}
+
+	setLinkHeader(w, r, page)
+
+	return WriteJSON(w, status, &envelope{Data: data, Page: page})
+}
+
+// setLinkHeader advertises the neighbouring pages as RFC 8288 links relative to
+// the current request URL.
+func setLinkHeader(w http.ResponseWriter, r *http.Request, page store.Page) {
+	var links []string
+
+	addLink := func(rel, cursor string) {
+		if cursor == "" {
+			return
+		}
+
+		u := *r.URL
+		qs := u.Query()
+		qs.Set("cursor", cursor)
+		u.RawQuery = qs.Encode()
+
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel))
+	}
+
+	addLink("next", page.NextCursor)
+	addLink("prev", page.PrevCursor)
+
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
 func ReadJSON(w http.ResponseWriter, r *http.Request, data any) error {
 	maxBytes := 1_048_578 // 1 mb
 	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
@@ -79,6 +125,36 @@ func (s *Server) notFoundError(w http.ResponseWriter, r *http.Request, err error
 	WriteJSONError(w, http.StatusBadRequest, "resource not found")
 }
 
+func (s *Server) conflictResponse(w http.ResponseWriter, r *http.Request, err error) {
+	s.Logger.Warnw("conflict", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	WriteJSONError(w, http.StatusConflict, err.Error())
+}
+
+func (s *Server) preconditionFailedResponse(w http.ResponseWriter, r *http.Request, err error) {
+	s.Logger.Warnw("precondition failed", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	WriteJSONError(w, http.StatusPreconditionFailed, err.Error())
+}
+
+func (s *Server) preconditionRequiredResponse(w http.ResponseWriter, r *http.Request, err error) {
+	s.Logger.Warnw("precondition required", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	WriteJSONError(w, http.StatusPreconditionRequired, err.Error())
+}
+
+func (s *Server) payloadTooLargeResponse(w http.ResponseWriter, r *http.Request, err error) {
+	s.Logger.Warnw("payload too large", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	WriteJSONError(w, http.StatusRequestEntityTooLarge, err.Error())
+}
+
+func (s *Server) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, err error) {
+	s.Logger.Warnw("unsupported media type", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+
+	WriteJSONError(w, http.StatusUnsupportedMediaType, err.Error())
+}
+
 func (s *Server) unauthorizedBasicError(w http.ResponseWriter, r *http.Request, err error) {
 	s.Logger.Warnf("unauthorized", r.Method, "path", r.URL.Path, "error", err.Error())
 
@@ -94,19 +170,35 @@ func (s *Server) unauthorizedError(w http.ResponseWriter, r *http.Request, err e
 }
 
 func (s *Server) postContextFetch(next http.Handler) http.Handler {
+	return s.postContext(func(r *http.Request, id int64) (*store.Post, error) {
+		return s.Store.Posts.GetVisible(r.Context(), id, getUserFromCtx(r).ID)
+	}, next)
+}
+
+func (s *Server) trashedPostContextFetch(next http.Handler) http.Handler {
+	return s.postContext(func(r *http.Request, id int64) (*store.Post, error) {
+		return s.Store.Posts.GetTrashed(r.Context(), id)
+	}, next)
+}
+
+func (s *Server) postContext(fetch func(r *http.Request, id int64) (*store.Post, error), next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		idParam := chi.URLParam(r, "postID")
 		id, err := strconv.ParseInt(idParam, 10, 64)
 		if err != nil {
-			s.internalServerError(w, r, err)
+			s.badRequest(w, r, err)
 			return
 		}
 
 		ctx := r.Context()
 
-		post, err := s.Store.Posts.GetByID(ctx, id)
+		post, err := fetch(r, id)
 		if err != nil {
-			s.notFoundError(w, r, err)
+			if errors.Is(err, store.ErrNotFound) {
+				s.notFoundError(w, r, err)
+				return
+			}
+			s.internalServerError(w, r, err)
 			return
 		}
 
@@ -115,6 +207,38 @@ func (s *Server) postContextFetch(next http.Handler) http.Handler {
 	})
 }
 
+func (s *Server) commentContextFetch(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idParam := chi.URLParam(r, "commentID")
+		id, err := strconv.ParseInt(idParam, 10, 64)
+		if err != nil {
+			s.badRequest(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		comment, err := s.Store.Comments.GetByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				s.notFoundError(w, r, err)
+				return
+			}
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		post := getPostFromCtx(r)
+		if comment.PostID != post.ID {
+			s.notFoundError(w, r, store.ErrNotFound)
+			return
+		}
+
+		ctx = context.WithValue(ctx, commentCtx, comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func (s *Server) userContext(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		rawUserID := chi.URLParam(r, "userID")
@@ -127,23 +251,81 @@ func (s *Server) userContext(next http.Handler) http.Handler {
 
 		ctx := r.Context()
 
-		user, err := s.Store.Users.GetByID(ctx, userID)
+		user, err := s.getUser(ctx, userID)
 		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				s.notFoundError(w, r, err)
+				return
+			}
 			s.internalServerError(w, r, err)
 			return
 		}
 
-		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, targetUserCtx, user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// postETag derives the entity tag of a post from its version.
+func postETag(post *store.Post) string {
+	return fmt.Sprintf(`"%d-%d"`, post.ID, post.Version)
+}
+
+func setPostETag(w http.ResponseWriter, post *store.Post) {
+	w.Header().Set("ETag", postETag(post))
+}
+
+// checkIfMatch enforces the If-Match precondition against the post the client
+// wants to modify. Requests without the header are refused, so clients cannot
+// overwrite concurrent edits by accident. Tags are compared strongly, as RFC
+// 7232 requires for If-Match: weak W/ tags never match.
+func (s *Server) checkIfMatch(w http.ResponseWriter, r *http.Request, post *store.Post) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		setPostETag(w, post)
+		s.preconditionRequiredResponse(w, r, errors.New("the If-Match header is required"))
+		return false
+	}
+
+	etag := postETag(post)
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	setPostETag(w, post)
+	s.preconditionFailedResponse(w, r, fmt.Errorf("%w: current version is %d", store.ErrEditConflict, post.Version))
+
+	return false
+}
+
 func getPostFromCtx(r *http.Request) *store.Post {
 	post, _ := r.Context().Value(postCtx).(*store.Post)
 	return post
 }
 
+func getCommentFromCtx(r *http.Request) *store.Comment {
+	comment, _ := r.Context().Value(commentCtx).(*store.Comment)
+	return comment
+}
+
 func getUserFromCtx(r *http.Request) *store.User {
 	user, _ := r.Context().Value(userCtx).(*store.User)
 	return user
 }
+
+// getTokenExpiryFromCtx returns when the token the request authenticated with
+// expires. Long-lived connections end there, as the token is only checked once.
+func getTokenExpiryFromCtx(r *http.Request) time.Time {
+	expiry, _ := r.Context().Value(tokenExpiryCtx).(time.Time)
+	return expiry
+}
+
+// getTargetUserFromCtx returns the user addressed by the {userID} URL parameter,
+// as opposed to the authenticated user.
+func getTargetUserFromCtx(r *http.Request) *store.User {
+	user, _ := r.Context().Value(targetUserCtx).(*store.User)
+	return user
+}
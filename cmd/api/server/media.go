@@ -0,0 +1,319 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/vesselchuckk/go-social/internal/media"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// uploadFormOverhead is the room left for the multipart envelope around the
+// uploaded file.
+const uploadFormOverhead = 1 << 20
+
+var (
+	errUploadTooLarge      = errors.New("the uploaded file is too large")
+	errUnsupportedFileType = errors.New("the uploaded file type is not supported")
+	errMediaProcessing     = errors.New("the media is still being processed")
+)
+
+// MEDIA HANDLER
+
+func (s *Server) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := s.storeUpload(w, r, store.AttachmentKindPost)
+	if !ok {
+		return
+	}
+
+	s.signAttachment(attachment)
+
+	if err := s.jsonResponse(w, http.StatusCreated, attachment); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) uploadAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := s.storeUpload(w, r, store.AttachmentKindAvatar)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+
+	if err := s.Store.Attachments.SetAvatar(ctx, user.ID, &attachment.ID); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if err := s.invalidateUser(ctx, user.ID); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	user.AvatarID = &attachment.ID
+	user.AvatarStatus = attachment.Status
+	s.signAvatar(user)
+
+	if err := s.jsonResponse(w, http.StatusOK, user); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) deleteAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+
+	if err := s.Store.Attachments.SetAvatar(ctx, user.ID, nil); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if err := s.invalidateUser(ctx, user.ID); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveMediaHandler streams an uploaded object, or one of its variants when
+// ?size= names one. Links are only valid with the signature handed out in API
+// responses, until it expires.
+func (s *Server) serveMediaHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "attachmentID"), 10, 64)
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	if !s.Signer.Verify(id, query.Get("expires"), query.Get("sig")) {
+		s.forbiddenResponse(w, r)
+		return
+	}
+
+	ctx := r.Context()
+
+	attachment, err := s.Store.Attachments.GetByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			s.notFoundError(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	switch attachment.Status {
+	case store.AttachmentStatusProcessing:
+		s.conflictResponse(w, r, errMediaProcessing)
+		return
+	case store.AttachmentStatusFailed:
+		s.notFoundError(w, r, errors.New("media processing failed"))
+		return
+	}
+
+	key, contentType, size := attachment.StorageKey, attachment.ContentType, attachment.SizeBytes
+	if name := query.Get("size"); name != "" {
+		i := slices.IndexFunc(attachment.Variants, func(v store.AttachmentVariant) bool { return v.Name == name })
+		if i < 0 {
+			s.notFoundError(w, r, fmt.Errorf("no %q variant", name))
+			return
+		}
+		variant := attachment.Variants[i]
+		key, contentType, size = variant.StorageKey, variant.ContentType, variant.SizeBytes
+	}
+
+	object, err := s.Media.Get(ctx, key)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrNotFound):
+			s.notFoundError(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+	defer object.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(s.Config.MediaURLTTL.Seconds())))
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, object); err != nil {
+		s.Logger.Warnw("failed to stream media", "attachment_id", id, "error", err.Error())
+	}
+}
+
+// storeUpload saves the "file" field of a multipart request as an attachment of
+// the given kind. It writes the error response itself and reports whether the
+// upload was stored.
+func (s *Server) storeUpload(w http.ResponseWriter, r *http.Request, kind string) (*store.Attachment, bool) {
+	data, contentType, err := s.readUpload(w, r)
+	if err != nil {
+		switch {
+		case errors.Is(err, errUploadTooLarge):
+			s.payloadTooLargeResponse(w, r, err)
+		case errors.Is(err, errUnsupportedFileType):
+			s.unsupportedMediaTypeResponse(w, r, err)
+		default:
+			s.badRequest(w, r, err)
+		}
+		return nil, false
+	}
+
+	ctx := r.Context()
+	user := getUserFromCtx(r)
+
+	attachment := &store.Attachment{
+		UserID:      user.ID,
+		Kind:        kind,
+		StorageKey:  fmt.Sprintf("%s/%s/%s", kind, user.ID, uuid.NewString()),
+		ContentType: contentType,
+		SizeBytes:   int64(len(data)),
+	}
+
+	if err := s.Media.Put(ctx, attachment.StorageKey, data, contentType); err != nil {
+		s.internalServerError(w, r, err)
+		return nil, false
+	}
+
+	if err := s.Store.Attachments.Create(ctx, attachment); err != nil {
+		if err := s.Media.Delete(context.Background(), attachment.StorageKey); err != nil {
+			s.Logger.Errorw("failed to remove unsaved upload", "key", attachment.StorageKey, "error", err.Error())
+		}
+		s.internalServerError(w, r, err)
+		return nil, false
+	}
+
+	s.queueMediaProcessing()
+
+	return attachment, true
+}
+
+// readUpload reads the "file" field of a multipart request, enforcing the size
+// limit, and sniffs its content type from the data rather than trusting the
+// client.
+func (s *Server) readUpload(w http.ResponseWriter, r *http.Request) ([]byte, string, error) {
+	maxSize := s.Config.MaxUploadSize
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+uploadFormOverhead)
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, "", errUploadTooLarge
+		}
+		return nil, "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if int64(len(data)) > maxSize {
+		return nil, "", errUploadTooLarge
+	}
+
+	if len(data) == 0 {
+		return nil, "", errors.New("the uploaded file is empty")
+	}
+
+	contentType := http.DetectContentType(data)
+	if !slices.Contains(s.Config.MediaTypes, contentType) {
+		return nil, "", fmt.Errorf("%w: %s", errUnsupportedFileType, contentType)
+	}
+
+	return data, contentType, nil
+}
+
+func (s *Server) mediaURL(id int64) string {
+	expires, signature := s.Signer.Sign(id)
+	return fmt.Sprintf("/v1/media/%d?expires=%d&sig=%s", id, expires, signature)
+}
+
+// signAttachment fills in the links to an attachment and its variants once it
+// can be served.
+func (s *Server) signAttachment(a *store.Attachment) {
+	if a.Status != store.AttachmentStatusReady {
+		return
+	}
+
+	a.URL = s.mediaURL(a.ID)
+	for i := range a.Variants {
+		a.Variants[i].URL = a.URL + "&size=" + a.Variants[i].Name
+	}
+}
+
+// signPostMedia fills in the links to the attachments of posts and of their
+// embedded originals.
+func (s *Server) signPostMedia(posts ...*store.Post) {
+	for _, p := range posts {
+		for _, q := range []*store.Post{p, p.Original} {
+			if q == nil {
+				continue
+			}
+			for i := range q.Attachments {
+				s.signAttachment(&q.Attachments[i])
+			}
+		}
+	}
+}
+
+// withMedia loads and signs the attachments of posts fetched one by one.
+func (s *Server) withMedia(ctx context.Context, posts ...*store.Post) error {
+	if err := s.Store.Posts.AttachMedia(ctx, posts...); err != nil {
+		return err
+	}
+
+	s.signPostMedia(posts...)
+
+	return nil
+}
+
+// signAvatar fills in the link to the avatar of a user once it can be served.
+func (s *Server) signAvatar(user *store.User) {
+	if user.AvatarID != nil && user.AvatarStatus == store.AttachmentStatusReady {
+		user.AvatarURL = s.mediaURL(*user.AvatarID)
+	}
+}
+
+// cleanupOrphanedMedia removes the uploads nothing refers to anymore, once they
+// are older than the orphan retention. Rows go first, so a failed object delete
+// only leaves an unreachable object behind.
+func (s *Server) cleanupOrphanedMedia(ctx context.Context) error {
+	for {
+		keys, err := s.Store.Attachments.DeleteOrphans(ctx, time.Now().Add(-s.Config.OrphanRetention), orphanBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if err := s.Media.Delete(ctx, key); err != nil {
+				s.Logger.Errorw("failed to remove orphaned media", "key", key, "error", err.Error())
+			}
+		}
+
+		if len(keys) > 0 {
+			s.Logger.Infow("removed orphaned media", "count", len(keys))
+		}
+
+		if len(keys) < orphanBatchSize {
+			return nil
+		}
+	}
+}
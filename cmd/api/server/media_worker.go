@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/vesselchuckk/go-social/internal/events"
+	"github.com/vesselchuckk/go-social/internal/media"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"io"
+	"time"
+)
+
+const (
+	mediaBatchSize = 10
+	// mediaLease is how long a claimed upload is left to its worker before
+	// another one may retry it.
+	mediaLease            = 5 * time.Minute
+	maxProcessingAttempts = 3
+)
+
+// queueMediaProcessing wakes the media worker without waiting for its next tick.
+func (s *Server) queueMediaProcessing() {
+	select {
+	case s.mediaQueued <- struct{}{}:
+	default:
+	}
+}
+
+// runMediaWorker processes uploads as they come in, and every process interval
+// to pick up the ones queued by other API processes or left by failed attempts.
+func (s *Server) runMediaWorker(ctx context.Context) {
+	ticker := time.NewTicker(s.Config.MediaProcessInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.processPendingMedia(ctx); err != nil {
+			s.Logger.Errorw("background job failed", "job", "media processing", "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.mediaQueued:
+		}
+	}
+}
+
+func (s *Server) processPendingMedia(ctx context.Context) error {
+	for {
+		claimed, err := s.Store.Attachments.ClaimForProcessing(ctx, mediaBatchSize, mediaLease, maxProcessingAttempts)
+		if err != nil {
+			return err
+		}
+
+		for i := range claimed {
+			s.processAttachment(ctx, &claimed[i])
+		}
+
+		if len(claimed) < mediaBatchSize {
+			return nil
+		}
+	}
+}
+
+// processAttachment replaces an upload with its processed form and stores its
+// variants. Uploads that are not valid images fail right away; other errors are
+// retried until the attempts run out.
+func (s *Server) processAttachment(ctx context.Context, a *store.Attachment) {
+	err := s.renderAttachment(ctx, a)
+	if err == nil {
+		err = s.Store.Attachments.CompleteProcessing(ctx, a)
+	}
+
+	if err != nil {
+		s.Logger.Warnw("failed to process media", "attachment_id", a.ID, "attempt", a.Attempts, "error", err.Error())
+
+		if !errors.Is(err, media.ErrUnsupportedImage) && a.Attempts < maxProcessingAttempts {
+			return
+		}
+
+		if err := s.Store.Attachments.FailProcessing(ctx, a.ID); err != nil {
+			s.Logger.Errorw("failed to mark media as failed", "attachment_id", a.ID, "error", err.Error())
+			return
+		}
+		a.Status = store.AttachmentStatusFailed
+	}
+
+	// Cached users carry the status of their avatar.
+	if a.Kind == store.AttachmentKindAvatar {
+		if err := s.invalidateUser(ctx, a.UserID); err != nil {
+			s.Logger.Errorw("failed to invalidate user", "user_id", a.UserID, "error", err.Error())
+		}
+	}
+
+	s.signAttachment(a)
+	s.pushEvent(ctx, a.UserID, events.TypeMedia, a)
+}
+
+func (s *Server) renderAttachment(ctx context.Context, a *store.Attachment) error {
+	object, err := s.Media.Get(ctx, a.StorageKey)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		return err
+	}
+
+	processed, err := media.ProcessImage(data, s.Config.ThumbnailSizes)
+	if err != nil {
+		return err
+	}
+
+	a.Variants = make([]store.AttachmentVariant, 0, len(processed.Variants))
+	for _, v := range processed.Variants {
+		variant := store.AttachmentVariant{
+			Name:        v.Name,
+			StorageKey:  a.StorageKey + "-" + v.Name,
+			ContentType: v.ContentType,
+			Width:       v.Width,
+			Height:      v.Height,
+			SizeBytes:   int64(len(v.Data)),
+		}
+
+		if err := s.Media.Put(ctx, variant.StorageKey, v.Data, v.ContentType); err != nil {
+			return err
+		}
+
+		a.Variants = append(a.Variants, variant)
+	}
+
+	// Overwrite the upload last: until then it still holds the original
+	// metadata, but it is not served before the attachment is ready.
+	original := processed.Original
+	if err := s.Media.Put(ctx, a.StorageKey, original.Data, original.ContentType); err != nil {
+		return err
+	}
+
+	a.ContentType = original.ContentType
+	a.SizeBytes = int64(len(original.Data))
+	a.Width = original.Width
+	a.Height = original.Height
+	a.BlurHash = processed.BlurHash
+
+	return nil
+}
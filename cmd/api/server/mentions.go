@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"net/http"
+)
+
+func (s *Server) recordCommentMentions(ctx context.Context, comment *store.Comment) {
+	s.recordMentions(ctx, comment.UserID, comment.PostID, &comment.ID, comment.Content)
+}
+
+func (s *Server) recordMentions(ctx context.Context, authorID uuid.UUID, postID int64, commentID *int64, content string) {
+	mentioned, err := s.Store.Mentions.Record(ctx, authorID, postID, commentID, content)
+	if err != nil {
+		s.Logger.Errorw("failed to record mentions", "post_id", postID, "error", err.Error())
+		return
+	}
+
+	for _, userID := range mentioned {
+		s.notify(ctx, &store.Notification{
+			UserID:    userID,
+			ActorID:   authorID,
+			Type:      store.NotificationMention,
+			PostID:    &postID,
+			CommentID: commentID,
+		})
+	}
+}
+
+// MENTIONS HANDLER
+
+func (s *Server) listMentionsHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedQuery{
+		Limit: 20,
+		Sort:  "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	mentions, page, err := s.Store.Mentions.List(r.Context(), user, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			s.badRequest(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := s.jsonPageResponse(w, r, http.StatusOK, mentions, page); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
@@ -95,7 +95,30 @@ func (s *Server) AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Resetting the password bumps the token version, revoking the tokens
+		// issued before. Tokens without a version predate it and count as 0.
+		var version float64
+		if rawVersion, ok := claims["ver"]; ok {
+			version, ok = rawVersion.(float64)
+			if !ok {
+				s.unauthorizedError(w, r, fmt.Errorf("invalid token version"))
+				return
+			}
+		}
+
+		if int(version) != user.TokenVersion {
+			s.unauthorizedError(w, r, fmt.Errorf("token has been revoked"))
+			return
+		}
+
+		expiry, err := claims.GetExpirationTime()
+		if err != nil || expiry == nil {
+			s.unauthorizedError(w, r, fmt.Errorf("invalid token expiry"))
+			return
+		}
+
 		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, tokenExpiryCtx, expiry.Time)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -125,6 +148,50 @@ func (s *Server) checkPostOwnership(role string, next http.HandlerFunc) http.Han
 	})
 }
 
+func (s *Server) checkCommentOwnership(role string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserFromCtx(r)
+		comment := getCommentFromCtx(r)
+
+		if comment.UserID == user.ID {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, err := s.checkRole(r.Context(), user, role)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			s.forbiddenResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) requireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserFromCtx(r)
+
+		allowed, err := s.checkRole(r.Context(), user, role)
+		if err != nil {
+			s.internalServerError(w, r, err)
+			return
+		}
+
+		if !allowed {
+			s.forbiddenResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (s *Server) checkRole(ctx context.Context, user *store.User, roleName string) (bool, error) {
 	role, err := s.Store.Roles.GetByName(ctx, roleName)
 	if err != nil {
@@ -135,6 +202,10 @@ func (s *Server) checkRole(ctx context.Context, user *store.User, roleName strin
 }
 
 func (s *Server) getUser(ctx context.Context, userID uuid.UUID) (*store.User, error) {
+	if !s.Config.RedisEnabled {
+		return s.Store.Users.GetByID(ctx, userID)
+	}
+
 	user, err := s.Redis.Users.Get(ctx, userID)
 	if err != nil {
 		return nil, err
@@ -153,3 +224,12 @@ func (s *Server) getUser(ctx context.Context, userID uuid.UUID) (*store.User, er
 
 	return user, nil
 }
+
+// invalidateUser drops the cached copy of a user after it changed.
+func (s *Server) invalidateUser(ctx context.Context, userID uuid.UUID) error {
+	if !s.Config.RedisEnabled {
+		return nil
+	}
+
+	return s.Redis.Users.Delete(ctx, userID)
+}
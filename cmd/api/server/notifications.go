@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/vesselchuckk/go-social/internal/events"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"net/http"
+)
+
+// NOTIFICATIONS PAYLOAD
+type MarkNotificationsReadRequest struct {
+	Cursor string `json:"cursor"`
+}
+
+// notify records a notification for its recipient. Users are never notified of
+// their own actions, and failures are logged rather than failing the request
+// that triggered them.
+func (s *Server) notify(ctx context.Context, n *store.Notification) {
+	if n.UserID == n.ActorID {
+		return
+	}
+
+	if err := s.Store.Notifications.Create(ctx, n); err != nil {
+		s.Logger.Errorw("failed to create notification", "type", n.Type, "user_id", n.UserID, "error", err.Error())
+		return
+	}
+
+	s.pushEvent(ctx, n.UserID, events.TypeNotification, n)
+}
+
+// NOTIFICATIONS HANDLER
+
+func (s *Server) listNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedQuery{
+		Limit: 20,
+		Sort:  "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	ctx := r.Context()
+
+	notifications, page, err := s.Store.Notifications.List(ctx, user, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			s.badRequest(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	unread, err := s.Store.Notifications.CountUnread(ctx, user.ID)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	type envelope struct {
+		Data        []store.Notification `json:"data"`
+		UnreadCount int                  `json:"unread_count"`
+		store.Page
+	}
+
+	setLinkHeader(w, r, page)
+
+	if err := WriteJSON(w, http.StatusOK, &envelope{Data: notifications, UnreadCount: unread, Page: page}); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) markNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	var req MarkNotificationsReadRequest
+	if err := ReadJSON(w, r, &req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	var cursor *store.Cursor
+	if req.Cursor != "" {
+		c, err := store.DecodeCursor(req.Cursor)
+		if err != nil {
+			s.badRequest(w, r, err)
+			return
+		}
+		cursor = c
+	}
+
+	user := getUserFromCtx(r)
+
+	ctx := r.Context()
+
+	if _, err := s.Store.Notifications.MarkRead(ctx, user.ID, cursor); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	unread, err := s.Store.Notifications.CountUnread(ctx, user.ID)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if err := s.jsonResponse(w, http.StatusOK, map[string]int{"unread_count": unread}); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
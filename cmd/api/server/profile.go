@@ -0,0 +1,140 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// usernamePattern keeps new usernames mentionable as @username.
+var usernamePattern = regexp.MustCompile(`^\w{3,96}$`)
+
+// PROFILE PAYLOAD
+type UpdateProfileRequest struct {
+	Username    *string `json:"username" validate:"omitempty,max=96"`
+	DisplayName *string `json:"display_name" validate:"omitempty,max=50"`
+	Bio         *string `json:"bio" validate:"omitempty,max=300"`
+	Location    *string `json:"location" validate:"omitempty,max=100"`
+	Website     *string `json:"website" validate:"omitempty,http_url,max=200"`
+}
+
+// PROFILE HANDLER
+
+func (s *Server) updateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	var req UpdateProfileRequest
+	if err := ReadJSON(w, r, &req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	// The user in the request context may come from the cache; edit a fresh copy.
+	user, err := s.Store.Users.GetByID(ctx, getUserFromCtx(r).ID)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if req.Username != nil {
+		if !usernamePattern.MatchString(*req.Username) {
+			s.badRequest(w, r, errors.New("usernames must be 3 to 96 letters, digits or underscores"))
+			return
+		}
+		user.Username = *req.Username
+	}
+	if req.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*req.DisplayName)
+	}
+	if req.Bio != nil {
+		user.Bio = strings.TrimSpace(*req.Bio)
+	}
+	if req.Location != nil {
+		user.Location = strings.TrimSpace(*req.Location)
+	}
+	if req.Website != nil {
+		user.Website = *req.Website
+	}
+
+	if err := s.Store.Users.UpdateProfile(ctx, user, s.Config.UsernameChangeCooldown); err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateUsername):
+			s.conflictResponse(w, r, err)
+		case errors.Is(err, store.ErrUsernameCooldown):
+			next := user.UsernameChangedAt.Add(s.Config.UsernameChangeCooldown)
+			s.badRequest(w, r, fmt.Errorf("%w, try again after %s", err, next.Format("2006-01-02 15:04 MST")))
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := s.invalidateUser(ctx, user.ID); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	s.signAvatar(user)
+
+	if err := s.jsonResponse(w, http.StatusOK, user); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+// getUserByUsernameHandler looks a user up by username. Former usernames
+// redirect permanently to the current one.
+func (s *Server) getUserByUsernameHandler(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+
+	ctx := r.Context()
+
+	userID, former, err := s.Store.Users.ResolveUsername(ctx, username)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			s.notFoundError(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if former {
+		location := strings.TrimSuffix(r.URL.Path, username) + url.PathEscape(user.Username)
+		http.Redirect(w, r, location, http.StatusMovedPermanently)
+		return
+	}
+
+	stats, err := s.Store.Followers.Stats(ctx, user.ID, getUserFromCtx(r).ID)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	s.signAvatar(user)
+
+	profile := UserProfile{
+		User:        user,
+		FollowStats: *stats,
+	}
+
+	if err := s.jsonResponse(w, http.StatusOK, profile); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
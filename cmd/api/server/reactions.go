@@ -0,0 +1,71 @@
+package server
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"net/http"
+	"slices"
+)
+
+// REACTIONS HANDLER
+
+func (s *Server) addReactionHandler(w http.ResponseWriter, r *http.Request) {
+	reactionType, ok := s.reactionTypeParam(w, r)
+	if !ok {
+		return
+	}
+
+	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
+
+	ctx := r.Context()
+
+	added, err := s.Store.Reactions.Add(ctx, post.ID, user.ID, reactionType)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if added {
+		s.notify(ctx, &store.Notification{
+			UserID:   post.UserID,
+			ActorID:  user.ID,
+			Type:     store.NotificationReaction,
+			PostID:   &post.ID,
+			Reaction: &reactionType,
+		})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) removeReactionHandler(w http.ResponseWriter, r *http.Request) {
+	reactionType, ok := s.reactionTypeParam(w, r)
+	if !ok {
+		return
+	}
+
+	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
+
+	if err := s.Store.Reactions.Remove(r.Context(), post.ID, user.ID, reactionType); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reactionTypeParam reads the reaction type from the URL and rejects the request
+// when it is not one of the configured types.
+func (s *Server) reactionTypeParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	reactionType := chi.URLParam(r, "reactionType")
+
+	if !slices.Contains(s.Config.ReactionTypes, reactionType) {
+		s.badRequest(w, r, fmt.Errorf("unsupported reaction type %q", reactionType))
+		return "", false
+	}
+
+	return reactionType, true
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"errors"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"net/http"
+)
+
+// REPOSTS PAYLOAD
+type CreateQuoteRequest struct {
+	Title   string `json:"title" validate:"max=100"`
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+// REPOSTS HANDLER
+
+func (s *Server) repostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	repost := &store.Post{
+		UserID:   user.ID,
+		Kind:     store.PostKindRepost,
+		RepostOf: originalPostID(getPostFromCtx(r)),
+	}
+
+	s.createShare(w, r, repost)
+}
+
+func (s *Server) quotePostHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateQuoteRequest
+	if err := ReadJSON(w, r, &req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(req); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	quote := &store.Post{
+		Title:    req.Title,
+		Content:  req.Content,
+		UserID:   user.ID,
+		Kind:     store.PostKindQuote,
+		RepostOf: originalPostID(getPostFromCtx(r)),
+	}
+
+	s.createShare(w, r, quote)
+}
+
+func (s *Server) undoRepostHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	originalID := originalPostID(getPostFromCtx(r))
+
+	if err := s.Store.Posts.DeleteRepost(r.Context(), user.ID, *originalID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			s.notFoundError(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) createShare(w http.ResponseWriter, r *http.Request, post *store.Post) {
+	if getPostFromCtx(r).Status != store.PostStatusPublished {
+		s.badRequest(w, r, errors.New("only published posts can be shared"))
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := s.Store.Posts.CreatePost(ctx, post); err != nil {
+		switch {
+		case errors.Is(err, store.ErrAlreadyReposted):
+			s.conflictResponse(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	s.postPublished(ctx, post)
+
+	if err := s.Store.Posts.AttachOriginals(ctx, getUserFromCtx(r).ID, post); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if err := s.withMedia(ctx, post); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if err := s.jsonResponse(w, http.StatusCreated, post); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+// originalPostID resolves the post a share should point to. Sharing a plain
+// repost shares the post it reposted instead.
+func originalPostID(post *store.Post) *int64 {
+	if post.Kind == store.PostKindRepost && post.RepostOf != nil {
+		return post.RepostOf
+	}
+
+	return &post.ID
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"net/http"
+	"strconv"
+)
+
+// RevisionComparison pairs a revision with the state that replaced it, so
+// clients can render the change made by a single edit.
+type RevisionComparison struct {
+	Revision *store.PostRevision `json:"revision"`
+	Next     *store.PostRevision `json:"next"`
+}
+
+// REVISIONS HANDLER
+
+func (s *Server) listRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	revisions, err := s.Store.Revisions.ListByPostID(r.Context(), post.ID)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if err := s.jsonResponse(w, http.StatusOK, revisions); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) getRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	revision, ok := s.fetchRevision(w, r)
+	if !ok {
+		return
+	}
+
+	var next *store.PostRevision
+	if revision.Version+1 == post.Version {
+		next = currentRevision(post)
+	} else {
+		var err error
+		next, err = s.Store.Revisions.GetByVersion(r.Context(), post.ID, revision.Version+1)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			s.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	resp := RevisionComparison{
+		Revision: revision,
+		Next:     next,
+	}
+
+	if err := s.jsonResponse(w, http.StatusOK, resp); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) restoreRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
+
+	if !s.checkIfMatch(w, r, post) {
+		return
+	}
+
+	revision, ok := s.fetchRevision(w, r)
+	if !ok {
+		return
+	}
+
+	post.Title = revision.Title
+	post.Content = revision.Content
+	post.Tags = revision.Tags
+
+	if err := s.Store.Posts.Update(r.Context(), post, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrEditConflict):
+			s.conflictResponse(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := s.withMedia(r.Context(), post); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	setPostETag(w, post)
+
+	if err := s.jsonResponse(w, http.StatusOK, post); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) fetchRevision(w http.ResponseWriter, r *http.Request) (*store.PostRevision, bool) {
+	post := getPostFromCtx(r)
+
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		s.badRequest(w, r, err)
+		return nil, false
+	}
+
+	revision, err := s.Store.Revisions.GetByVersion(r.Context(), post.ID, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			s.notFoundError(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return revision, true
+}
+
+// currentRevision presents the live post in the same shape as a stored revision.
+func currentRevision(post *store.Post) *store.PostRevision {
+	return &store.PostRevision{
+		PostID:    post.ID,
+		Version:   post.Version,
+		Title:     post.Title,
+		Content:   post.Content,
+		Tags:      post.Tags,
+		CreatedAt: post.UpdatedAt,
+	}
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/vesselchuckk/go-social/internal/events"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"golang.org/x/net/websocket"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxSocketMessage caps the size of a single frame read from a client.
+	maxSocketMessage = 16 << 10
+	// socketProtocol is the subprotocol clients that cannot set headers, such
+	// as browsers, offer along with their bearer token.
+	socketProtocol = "bearer"
+)
+
+// socketEventTypes are the events forwarded to direct message sockets.
+var socketEventTypes = map[string]bool{
+	events.TypeMessage: true,
+	events.TypeTyping:  true,
+	events.TypeRead:    true,
+}
+
+// SocketFrame is a command sent by a client over the direct message socket.
+type SocketFrame struct {
+	Type           string `json:"type"`
+	ConversationID int64  `json:"conversation_id"`
+	Content        string `json:"content,omitempty"`
+	MessageID      int64  `json:"message_id,omitempty"`
+}
+
+type socketError struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// tokenFromProtocol lets clients that cannot set headers, such as browsers
+// opening a WebSocket, pass their bearer token as a subprotocol offered after
+// socketProtocol. Tokens are kept out of the URL so they never reach the access
+// logs.
+func (s *Server) tokenFromProtocol(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		protocols := socketProtocols(r)
+		if len(protocols) == 2 && protocols[0] == socketProtocol && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+protocols[1])
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func socketProtocols(r *http.Request) []string {
+	var protocols []string
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			if protocol = strings.TrimSpace(protocol); protocol != "" {
+				protocols = append(protocols, protocol)
+			}
+		}
+	}
+
+	return protocols
+}
+
+// acceptSocketProtocol answers the handshake with socketProtocol when the client
+// offered it, never echoing the token back.
+func acceptSocketProtocol(config *websocket.Config, r *http.Request) error {
+	if slices.Contains(config.Protocol, socketProtocol) {
+		config.Protocol = []string{socketProtocol}
+	} else {
+		config.Protocol = nil
+	}
+
+	return nil
+}
+
+// SOCKET HANDLER
+
+// conversationsSocketHandler upgrades to a WebSocket that delivers messages,
+// typing indicators and read receipts live, and accepts the same as commands.
+// Clients resume after a disconnect with the last_event_id query parameter.
+func (s *Server) conversationsSocketHandler(w http.ResponseWriter, r *http.Request) {
+	lastEventID := uint64(math.MaxUint64)
+	if rawLastID := r.URL.Query().Get("last_event_id"); rawLastID != "" {
+		id, err := strconv.ParseUint(rawLastID, 10, 64)
+		if err != nil {
+			s.badRequest(w, r, err)
+			return
+		}
+		lastEventID = id
+	}
+
+	user := getUserFromCtx(r)
+	expiry := getTokenExpiryFromCtx(r)
+
+	handler := func(conn *websocket.Conn) {
+		defer conn.Close()
+
+		conn.MaxPayloadBytes = maxSocketMessage
+
+		// The token is only checked at the handshake, so the socket closes
+		// when it expires and the client reconnects with a fresh one.
+		ctx, cancel := context.WithDeadline(r.Context(), expiry)
+		defer cancel()
+
+		stream, err := s.Events.Subscribe(ctx, user.ID, lastEventID)
+		if err != nil {
+			s.Logger.Errorw("failed to subscribe socket", "user_id", user.ID, "error", err.Error())
+			return
+		}
+
+		failures := make(chan error, 1)
+		go s.readSocket(ctx, conn, user, failures, cancel)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-failures:
+				if err := websocket.JSON.Send(conn, socketError{Type: "error", Error: err.Error()}); err != nil {
+					return
+				}
+			case event, ok := <-stream:
+				if !ok {
+					return
+				}
+
+				if revokesSession(event, user) {
+					websocket.JSON.Send(conn, event)
+					return
+				}
+
+				if !socketEventTypes[event.Type] {
+					continue
+				}
+
+				if err := websocket.JSON.Send(conn, event); err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	websocket.Server{Handler: handler, Handshake: acceptSocketProtocol}.ServeHTTP(w, r)
+}
+
+// readSocket handles the commands of a client until the connection closes.
+// Rejected commands are reported through failures; only the writer loop
+// writes to the connection.
+func (s *Server) readSocket(ctx context.Context, conn *websocket.Conn, user *store.User, failures chan<- error, closed context.CancelFunc) {
+	defer closed()
+
+	for {
+		var frame SocketFrame
+		if err := websocket.JSON.Receive(conn, &frame); err != nil {
+			return
+		}
+
+		if err := s.handleSocketFrame(ctx, user, frame); err != nil {
+			select {
+			case failures <- err:
+			default:
+			}
+		}
+	}
+}
+
+func (s *Server) handleSocketFrame(ctx context.Context, user *store.User, frame SocketFrame) error {
+	conv, err := s.Store.Conversations.GetForMember(ctx, frame.ConversationID, user.ID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("conversation %d: %w", frame.ConversationID, err)
+		}
+		s.Logger.Errorw("failed to load conversation", "conversation_id", frame.ConversationID, "error", err.Error())
+		return errors.New("error occurred on the server side")
+	}
+
+	switch frame.Type {
+	case events.TypeMessage:
+		if frame.Content == "" || utf8.RuneCountInString(frame.Content) > 2000 {
+			return errors.New("message content must be 1 to 2000 characters")
+		}
+		_, err = s.sendMessage(ctx, conv, user, frame.Content)
+	case events.TypeTyping:
+		s.signalTyping(ctx, conv, user)
+	case events.TypeRead:
+		if frame.MessageID < 1 {
+			return errors.New("message_id is required")
+		}
+		err = s.markConversationRead(ctx, conv, user, frame.MessageID)
+	default:
+		return fmt.Errorf("unknown frame type %q", frame.Type)
+	}
+
+	if errors.Is(err, errConversationBlocked) {
+		return err
+	}
+
+	if err != nil {
+		s.Logger.Errorw("socket command failed", "type", frame.Type, "conversation_id", conv.ID, "error", err.Error())
+		return errors.New("error occurred on the server side")
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultTagSuggestions = 10
+	maxTagSuggestions     = 50
+)
+
+// TAGS HANDLER
+
+func (s *Server) searchTagsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	prefix, err := store.NormalizeTag(qs.Get("prefix"))
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	limit := defaultTagSuggestions
+	if rawLimit := qs.Get("limit"); rawLimit != "" {
+		l, err := strconv.Atoi(rawLimit)
+		if err != nil || l < 1 || l > maxTagSuggestions {
+			s.badRequest(w, r, errors.New("limit must be between 1 and 50"))
+			return
+		}
+		limit = l
+	}
+
+	tags, err := s.Store.Tags.Search(r.Context(), prefix, limit)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if err := s.jsonResponse(w, http.StatusOK, tags); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) listTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	tag, err := store.NormalizeTag(chi.URLParam(r, "tag"))
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	fq := store.PaginatedQuery{
+		Limit: 20,
+		Sort:  "desc",
+	}
+
+	fq, err = fq.Parse(r)
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	posts, page, err := s.Store.Posts.GetByTag(r.Context(), user, tag, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			s.badRequest(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	for i := range posts {
+		s.signPostMedia(&posts[i].Post)
+	}
+
+	if err := s.jsonPageResponse(w, r, http.StatusOK, posts, page); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) followTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, err := store.NormalizeTag(chi.URLParam(r, "tag"))
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := s.Store.Tags.Follow(r.Context(), user.ID, tag); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) unfollowTagHandler(w http.ResponseWriter, r *http.Request) {
+	tag, err := store.NormalizeTag(chi.URLParam(r, "tag"))
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	if err := s.Store.Tags.Unfollow(r.Context(), user.ID, tag); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listFollowedTagsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+
+	tags, err := s.Store.Tags.ListFollowed(r.Context(), user.ID)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if err := s.jsonResponse(w, http.StatusOK, tags); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
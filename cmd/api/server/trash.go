@@ -0,0 +1,116 @@
+package server
+
+import (
+	"errors"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"net/http"
+)
+
+// TRASH HANDLER
+
+func (s *Server) listTrashHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedQuery{
+		Limit: 20,
+		Sort:  "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	trash, page, err := s.Store.Posts.GetTrash(r.Context(), user, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			s.badRequest(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	for i := range trash {
+		s.signPostMedia(&trash[i].Post)
+	}
+
+	if err := s.jsonPageResponse(w, r, http.StatusOK, trash, page); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) listDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedQuery{
+		Limit: 20,
+		Sort:  "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		s.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+
+	drafts, page, err := s.Store.Posts.GetDrafts(r.Context(), user, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCursor):
+			s.badRequest(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	for i := range drafts {
+		s.signPostMedia(&drafts[i].Post)
+	}
+
+	if err := s.jsonPageResponse(w, r, http.StatusOK, drafts, page); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+func (s *Server) restorePostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	if err := s.Store.Posts.Restore(r.Context(), post.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			s.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrAlreadyReposted):
+			s.conflictResponse(w, r, err)
+		default:
+			s.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	post.DeletedAt = nil
+
+	if err := s.withMedia(r.Context(), post); err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	setPostETag(w, post)
+
+	if err := s.jsonResponse(w, http.StatusOK, post); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
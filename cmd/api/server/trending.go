@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"net/http"
+	"strconv"
+)
+
+// trendingCacheSize is how many trending tags are computed and cached per
+// window; requests slice their own limit out of it.
+const trendingCacheSize = 50
+
+// TRENDING HANDLER
+
+func (s *Server) trendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	name := qs.Get("window")
+	if name == "" {
+		name = "24h"
+	}
+
+	window, ok := store.TrendingWindows[name]
+	if !ok {
+		s.badRequest(w, r, fmt.Errorf("unknown trending window %q, use 1h, 24h or 7d", name))
+		return
+	}
+
+	limit := defaultTagSuggestions
+	if rawLimit := qs.Get("limit"); rawLimit != "" {
+		l, err := strconv.Atoi(rawLimit)
+		if err != nil || l < 1 || l > trendingCacheSize {
+			s.badRequest(w, r, fmt.Errorf("limit must be between 1 and %d", trendingCacheSize))
+			return
+		}
+		limit = l
+	}
+
+	tags, err := s.getTrending(r.Context(), window)
+	if err != nil {
+		s.internalServerError(w, r, err)
+		return
+	}
+
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+
+	if err := s.jsonResponse(w, http.StatusOK, tags); err != nil {
+		s.internalServerError(w, r, err)
+	}
+}
+
+// getTrending serves the trending tags of a window from Redis, computing and
+// caching them on a miss. Without Redis every call aggregates in SQL.
+func (s *Server) getTrending(ctx context.Context, window store.TrendingWindow) ([]store.TrendingTag, error) {
+	if !s.Config.RedisEnabled {
+		return s.Store.Tags.Trending(ctx, window, trendingCacheSize)
+	}
+
+	tags, err := s.Redis.Trending.Get(ctx, window.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if tags == nil {
+		tags, err = s.Store.Tags.Trending(ctx, window, trendingCacheSize)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.Redis.Trending.Set(ctx, window.Name, tags); err != nil {
+			return nil, err
+		}
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,5 @@
+ALTER TABLE comments
+DROP COLUMN version;
+
+ALTER TABLE comments
+DROP COLUMN updated_at;
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE comments
+ADD COLUMN version INT NOT NULL DEFAULT 0;
+
+ALTER TABLE comments
+ADD COLUMN updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP;
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS post_reactions;
\ No newline at end of file
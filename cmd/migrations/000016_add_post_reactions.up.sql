@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS post_reactions (
+    post_id BIGINT NOT NULL,
+    user_id UUID NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (post_id, user_id, type),
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
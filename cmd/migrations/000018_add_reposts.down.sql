@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_posts_unique_repost;
+DROP INDEX IF EXISTS idx_posts_repost_of;
+
+DELETE FROM posts WHERE kind = 'repost';
+
+ALTER TABLE posts
+DROP COLUMN repost_of;
+
+ALTER TABLE posts
+DROP COLUMN kind;
@@ -0,0 +1,8 @@
+ALTER TABLE posts
+ADD COLUMN kind VARCHAR(16) NOT NULL DEFAULT 'post';
+
+ALTER TABLE posts
+ADD COLUMN repost_of BIGINT REFERENCES posts (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_posts_repost_of ON posts (repost_of);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_unique_repost ON posts (user_id, repost_of) WHERE kind = 'repost';
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    post_id BIGINT NOT NULL,
+    version INT NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    tags VARCHAR(100) [],
+    edited_by UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE (post_id, version),
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+    FOREIGN KEY (edited_by) REFERENCES users (id) ON DELETE SET NULL
+);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_posts_unique_repost;
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+
+DELETE FROM posts WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE posts
+DROP COLUMN deleted_at;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_unique_repost ON posts (user_id, repost_of) WHERE kind = 'repost';
@@ -0,0 +1,7 @@
+ALTER TABLE posts
+ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at) WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_posts_unique_repost;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_unique_repost ON posts (user_id, repost_of) WHERE kind = 'repost' AND deleted_at IS NULL;
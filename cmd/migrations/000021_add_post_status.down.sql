@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_posts_scheduled;
+
+ALTER TABLE posts
+DROP CONSTRAINT chk_posts_status;
+
+ALTER TABLE posts
+DROP COLUMN publish_at;
+
+ALTER TABLE posts
+DROP COLUMN status;
@@ -0,0 +1,10 @@
+ALTER TABLE posts
+ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'published';
+
+ALTER TABLE posts
+ADD COLUMN publish_at TIMESTAMPTZ;
+
+ALTER TABLE posts
+ADD CONSTRAINT chk_posts_status CHECK (status IN ('draft', 'scheduled', 'published'));
+
+CREATE INDEX IF NOT EXISTS idx_posts_scheduled ON posts (publish_at) WHERE status = 'scheduled';
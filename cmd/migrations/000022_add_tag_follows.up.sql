@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS tag_follows (
+    user_id UUID NOT NULL,
+    tag VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (user_id, tag),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS mentions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    author_id UUID NOT NULL,
+    post_id BIGINT NOT NULL,
+    comment_id BIGINT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (author_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_mentions_user_created_at ON mentions (user_id, created_at);
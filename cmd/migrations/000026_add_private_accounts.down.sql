@@ -0,0 +1,9 @@
+DELETE FROM notifications WHERE type IN ('follow_request', 'follow_accepted');
+ALTER TABLE notifications DROP CONSTRAINT IF EXISTS notifications_type_check;
+ALTER TABLE notifications ADD CONSTRAINT notifications_type_check
+    CHECK (type IN ('follow', 'comment', 'reply', 'mention', 'reaction'));
+
+DROP TABLE IF EXISTS follow_requests;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS is_private;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS blocks (
+    blocker_id UUID NOT NULL,
+    blocked_id UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (blocker_id, blocked_id),
+    FOREIGN KEY (blocker_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (blocked_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_blocks_blocked ON blocks (blocked_id);
+
+CREATE TABLE IF NOT EXISTS mutes (
+    muter_id UUID NOT NULL,
+    muted_id UUID NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (muter_id, muted_id),
+    FOREIGN KEY (muter_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (muted_id) REFERENCES users (id) ON DELETE CASCADE
+);
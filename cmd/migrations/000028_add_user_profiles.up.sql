@@ -0,0 +1,17 @@
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS display_name VARCHAR(50) NOT NULL DEFAULT '',
+ADD COLUMN IF NOT EXISTS bio VARCHAR(300) NOT NULL DEFAULT '',
+ADD COLUMN IF NOT EXISTS location VARCHAR(100) NOT NULL DEFAULT '',
+ADD COLUMN IF NOT EXISTS website VARCHAR(200) NOT NULL DEFAULT '',
+ADD COLUMN IF NOT EXISTS username_changed_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS username_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    username VARCHAR(96) NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_username_history_username ON username_history (LOWER(username), changed_at);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS attachments (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    post_id BIGINT,
+    position SMALLINT NOT NULL DEFAULT 0,
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('post', 'avatar')),
+    storage_key TEXT NOT NULL UNIQUE,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_post_id ON attachments (post_id, position);
+CREATE INDEX IF NOT EXISTS idx_attachments_unlinked ON attachments (created_at) WHERE post_id IS NULL;
+
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS avatar_id BIGINT REFERENCES attachments (id) ON DELETE SET NULL;
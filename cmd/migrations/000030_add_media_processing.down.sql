@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS attachment_variants;
+
+DROP INDEX IF EXISTS idx_attachments_processing;
+
+ALTER TABLE attachments
+DROP COLUMN IF EXISTS status,
+DROP COLUMN IF EXISTS width,
+DROP COLUMN IF EXISTS height,
+DROP COLUMN IF EXISTS blurhash,
+DROP COLUMN IF EXISTS attempts,
+DROP COLUMN IF EXISTS processing_started_at;
@@ -0,0 +1,25 @@
+-- Existing uploads predate processing and are served as they are.
+ALTER TABLE attachments
+ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'ready' CHECK (status IN ('processing', 'ready', 'failed')),
+ADD COLUMN IF NOT EXISTS width INT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS height INT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS blurhash VARCHAR(64) NOT NULL DEFAULT '',
+ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS processing_started_at TIMESTAMPTZ;
+
+ALTER TABLE attachments ALTER COLUMN status SET DEFAULT 'processing';
+
+CREATE INDEX IF NOT EXISTS idx_attachments_processing ON attachments (created_at) WHERE status = 'processing';
+
+CREATE TABLE IF NOT EXISTS attachment_variants (
+    attachment_id BIGINT NOT NULL,
+    name VARCHAR(16) NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE,
+    content_type VARCHAR(100) NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+
+    PRIMARY KEY (attachment_id, name),
+    FOREIGN KEY (attachment_id) REFERENCES attachments (id) ON DELETE CASCADE
+);
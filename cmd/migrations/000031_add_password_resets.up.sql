@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS password_resets (
+    token bytea PRIMARY KEY,
+    user_id UUID NOT NULL,
+    expiry TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_resets_user_id ON password_resets (user_id);
+
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS tokens_valid_after TIMESTAMPTZ;
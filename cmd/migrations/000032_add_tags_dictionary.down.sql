@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS posts_record_tags ON posts;
+
+DROP FUNCTION IF EXISTS record_post_tags();
+
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,22 @@
+-- Every tag ever used, so tag autocomplete can find prefix matches through an
+-- index before counting their posts through idx_posts_tags.
+CREATE TABLE IF NOT EXISTS tags (
+    tag VARCHAR(100) PRIMARY KEY
+);
+
+CREATE INDEX IF NOT EXISTS idx_tags_prefix ON tags (tag text_pattern_ops);
+
+INSERT INTO tags (tag)
+SELECT DISTINCT unnest(tags) FROM posts
+ON CONFLICT DO NOTHING;
+
+CREATE OR REPLACE FUNCTION record_post_tags() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO tags (tag) SELECT unnest(NEW.tags) ON CONFLICT DO NOTHING;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER posts_record_tags
+AFTER INSERT OR UPDATE OF tags ON posts
+FOR EACH ROW EXECUTE FUNCTION record_post_tags();
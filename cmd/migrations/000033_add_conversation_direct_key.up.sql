@@ -0,0 +1,20 @@
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS direct_key VARCHAR(73);
+
+-- The key of a one-to-one conversation is its ordered pair of members. Pairs
+-- that already got duplicated keep their oldest conversation as the direct one.
+UPDATE conversations c
+SET direct_key = d.direct_key
+FROM (
+    SELECT DISTINCT ON (pair.direct_key) pair.conversation_id, pair.direct_key
+    FROM (
+        SELECT m.conversation_id, string_agg(m.user_id::text, ':' ORDER BY m.user_id) AS direct_key
+        FROM conversation_members m
+        JOIN conversations g ON g.id = m.conversation_id AND g.is_group = false
+        GROUP BY m.conversation_id
+        HAVING COUNT(*) = 2
+    ) pair
+    ORDER BY pair.direct_key, pair.conversation_id
+) d
+WHERE c.id = d.conversation_id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_conversations_direct_key ON conversations (direct_key);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_password_resets_requested_ip;
+
+ALTER TABLE password_resets
+DROP COLUMN IF EXISTS requested_ip,
+DROP COLUMN IF EXISTS created_at;
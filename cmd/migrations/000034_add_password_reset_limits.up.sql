@@ -0,0 +1,5 @@
+ALTER TABLE password_resets
+ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+ADD COLUMN IF NOT EXISTS requested_ip INET;
+
+CREATE INDEX IF NOT EXISTS idx_password_resets_requested_ip ON password_resets (requested_ip, created_at);
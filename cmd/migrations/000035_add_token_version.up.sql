@@ -0,0 +1,8 @@
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS token_version INT NOT NULL DEFAULT 0;
+
+-- Tokens carry no version yet, so the ones of users who already reset their
+-- password stay revoked.
+UPDATE users SET token_version = 1 WHERE tokens_valid_after IS NOT NULL;
+
+ALTER TABLE users DROP COLUMN IF EXISTS tokens_valid_after;
@@ -0,0 +1,18 @@
+-- Usernames are looked up case-insensitively, so they must also be unique
+-- regardless of case. Where usernames already collide, the oldest account
+-- keeps its name and the others get a suffix from their ID; their old name
+-- goes to the username history.
+WITH ranked AS (
+    SELECT id, username, ROW_NUMBER() OVER (PARTITION BY LOWER(username) ORDER BY created_at, id) AS n
+    FROM users
+), renamed AS (
+    UPDATE users u
+    SET username = LEFT(r.username, 87) || '_' || LEFT(u.id::text, 8)
+    FROM ranked r
+    WHERE u.id = r.id AND r.n > 1
+    RETURNING u.id, r.username AS old_username
+)
+INSERT INTO username_history (user_id, username)
+SELECT id, old_username FROM renamed;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower ON users (LOWER(username));
@@ -0,0 +1,16 @@
+DROP TRIGGER IF EXISTS posts_record_tags ON posts;
+
+CREATE OR REPLACE FUNCTION record_post_tags() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO tags (tag) SELECT unnest(NEW.tags) ON CONFLICT DO NOTHING;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER posts_record_tags
+AFTER INSERT OR UPDATE OF tags ON posts
+FOR EACH ROW EXECUTE FUNCTION record_post_tags();
+
+DROP FUNCTION IF EXISTS count_tags(VARCHAR(100)[], INT);
+
+ALTER TABLE tags DROP COLUMN IF EXISTS post_count;
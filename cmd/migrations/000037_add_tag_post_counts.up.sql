@@ -0,0 +1,50 @@
+-- The number of published posts outside the trash using each tag, so tag
+-- autocomplete can pick its candidates by use instead of alphabetically.
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS post_count INT NOT NULL DEFAULT 0;
+
+UPDATE tags t
+SET post_count = c.post_count
+FROM (
+    SELECT tag, COUNT(*) AS post_count
+    FROM posts, unnest(tags) AS tag
+    WHERE status = 'published' AND deleted_at IS NULL
+    GROUP BY tag
+) c
+WHERE t.tag = c.tag;
+
+-- Tags are updated in order so concurrent posts sharing tags cannot deadlock.
+CREATE OR REPLACE FUNCTION count_tags(used VARCHAR(100)[], delta INT) RETURNS void AS $$
+DECLARE
+    used_tag VARCHAR(100);
+BEGIN
+    FOR used_tag IN SELECT DISTINCT unnest(used) ORDER BY 1 LOOP
+        UPDATE tags SET post_count = post_count + delta WHERE tag = used_tag;
+    END LOOP;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION record_post_tags() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP <> 'INSERT' AND OLD.status = 'published' AND OLD.deleted_at IS NULL THEN
+        PERFORM count_tags(OLD.tags, -1);
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+
+    INSERT INTO tags (tag) SELECT unnest(NEW.tags) ON CONFLICT DO NOTHING;
+
+    IF NEW.status = 'published' AND NEW.deleted_at IS NULL THEN
+        PERFORM count_tags(NEW.tags, 1);
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_record_tags ON posts;
+
+CREATE TRIGGER posts_record_tags
+AFTER INSERT OR UPDATE OF tags, status, deleted_at OR DELETE ON posts
+FOR EACH ROW EXECUTE FUNCTION record_post_tags();
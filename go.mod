@@ -16,6 +16,8 @@ require (
 	github.com/sendgrid/sendgrid-go v3.16.0+incompatible
 	go.uber.org/zap v1.27.0
 	golang.org/x/crypto v0.33.0
+	golang.org/x/image v0.25.0
+	golang.org/x/net v0.34.0
 )
 
 require (
@@ -27,7 +29,6 @@ require (
 	github.com/leodido/go-urn v1.4.0 // indirect
 	github.com/sendgrid/rest v2.6.9+incompatible // indirect
 	go.uber.org/multierr v1.11.0 // indirect
-	golang.org/x/net v0.34.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
-	golang.org/x/text v0.22.0 // indirect
+	golang.org/x/text v0.23.0 // indirect
 )
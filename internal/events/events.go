@@ -0,0 +1,74 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	TypeNotification = "notification"
+	TypeFeed         = "feed"
+	TypeMessage      = "message"
+	TypeTyping       = "typing"
+	TypeRead         = "read"
+	TypeMedia        = "media"
+	// TypeSessionRevoked tells the streams of a user that the tokens older than
+	// a version were revoked, so the ones opened with them must close.
+	TypeSessionRevoked = "session_revoked"
+)
+
+const (
+	// replaySize is how many past events are kept per user for clients that
+	// resume with Last-Event-ID.
+	replaySize = 100
+	// replayTTL is how long the replay buffer of an idle user is kept.
+	replayTTL = 24 * time.Hour
+	// subscriberBuffer is how many live events may queue up for a slow
+	// subscriber before new ones are dropped.
+	subscriberBuffer = 64
+)
+
+// Event is a message pushed to one user. IDs increase per user, so a client can
+// resume a stream after the last ID it saw.
+type Event struct {
+	ID   uint64          `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// SessionRevoked is the data of a TypeSessionRevoked event.
+type SessionRevoked struct {
+	TokenVersion int `json:"token_version"`
+}
+
+// Broker fans events out to the live subscriptions of a user.
+type Broker interface {
+	// Publish sends an event of the given type to every subscription of the user.
+	Publish(ctx context.Context, userID uuid.UUID, eventType string, data any) error
+	// Subscribe streams the events of the user until ctx is cancelled, starting
+	// with the buffered events newer than lastEventID. The channel is closed
+	// when the subscription ends.
+	Subscribe(ctx context.Context, userID uuid.UUID, lastEventID uint64) (<-chan Event, error)
+	// Signal sends a transient event, such as a typing indicator, to the live
+	// subscriptions of the user. Signals carry no ID and are never replayed.
+	Signal(ctx context.Context, userID uuid.UUID, eventType string, data any) error
+}
+
+// Sweeper is implemented by brokers that keep the streams of users in the
+// process, and must be swept periodically to drop the idle ones.
+type Sweeper interface {
+	// Sweep drops the streams without subscriptions whose replay buffer has
+	// expired, and returns how many were dropped.
+	Sweep() int
+}
+
+func newEvent(id uint64, eventType string, data any) (Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{ID: id, Type: eventType, Data: payload}, nil
+}
@@ -0,0 +1,140 @@
+package events
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+// MemoryBroker delivers events within a single API process.
+type MemoryBroker struct {
+	mu      sync.Mutex
+	streams map[uuid.UUID]*stream
+}
+
+type stream struct {
+	seq    uint64
+	replay []Event
+	subs   map[chan Event]struct{}
+	// lastActive is when the stream was last published to or subscribed to.
+	lastActive time.Time
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		streams: make(map[uuid.UUID]*stream),
+	}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, userID uuid.UUID, eventType string, data any) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st := b.stream(userID)
+
+	event, err := newEvent(st.seq+1, eventType, data)
+	if err != nil {
+		return err
+	}
+	st.seq++
+	st.lastActive = time.Now()
+
+	st.replay = append(st.replay, event)
+	if len(st.replay) > replaySize {
+		st.replay = st.replay[len(st.replay)-replaySize:]
+	}
+
+	for ch := range st.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+
+	return nil
+}
+
+func (b *MemoryBroker) Signal(ctx context.Context, userID uuid.UUID, eventType string, data any) error {
+	event, err := newEvent(0, eventType, data)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.stream(userID).subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, userID uuid.UUID, lastEventID uint64) (<-chan Event, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st := b.stream(userID)
+
+	var missed []Event
+	for _, event := range st.replay {
+		if event.ID > lastEventID {
+			missed = append(missed, event)
+		}
+	}
+
+	ch := make(chan Event, len(missed)+subscriberBuffer)
+	for _, event := range missed {
+		ch <- event
+	}
+	st.subs[ch] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(st.subs, ch)
+		close(ch)
+		st.lastActive = time.Now()
+	}()
+
+	return ch, nil
+}
+
+// stream returns the stream of the user, creating it on first use. The caller
+// must hold b.mu.
+func (b *MemoryBroker) stream(userID uuid.UUID) *stream {
+	st, ok := b.streams[userID]
+	if !ok {
+		st = &stream{subs: make(map[chan Event]struct{})}
+		b.streams[userID] = st
+	}
+	st.lastActive = time.Now()
+
+	return st
+}
+
+// Sweep drops the streams nobody is subscribed to and that saw no activity
+// within the replay TTL, the same window Redis keeps an idle buffer for.
+func (b *MemoryBroker) Sweep() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cutoff := time.Now().Add(-replayTTL)
+
+	var swept int
+	for userID, st := range b.streams {
+		if len(st.subs) == 0 && st.lastActive.Before(cutoff) {
+			delete(b.streams, userID)
+			swept++
+		}
+	}
+
+	return swept
+}
@@ -0,0 +1,143 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// RedisBroker delivers events across API instances through Redis pub/sub. The
+// per-user sequence and replay buffer live in Redis too, so a client can resume
+// on any instance.
+type RedisBroker struct {
+	rdb *redis.Client
+}
+
+func NewRedisBroker(rdb *redis.Client) *RedisBroker {
+	return &RedisBroker{rdb: rdb}
+}
+
+// publishScript assigns the next sequence number of a user and buffers and
+// publishes the event in one step, so events reach the replay buffer and the
+// channel in sequence order even when published concurrently.
+//
+// KEYS: sequence, replay buffer, channel. ARGV: event type, JSON data, replay
+// size, TTL in seconds.
+var publishScript = redis.NewScript(`
+local seq = redis.call('INCR', KEYS[1])
+local payload = '{"id":' .. seq .. ',"type":' .. cjson.encode(ARGV[1]) .. ',"data":' .. ARGV[2] .. '}'
+redis.call('LPUSH', KEYS[2], payload)
+redis.call('LTRIM', KEYS[2], 0, tonumber(ARGV[3]) - 1)
+redis.call('EXPIRE', KEYS[2], ARGV[4])
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+redis.call('PUBLISH', KEYS[3], payload)
+return seq
+`)
+
+func (b *RedisBroker) Publish(ctx context.Context, userID uuid.UUID, eventType string, data any) error {
+	event, err := newEvent(0, eventType, data)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{seqKey(userID), replayKey(userID), channelName(userID)}
+	return publishScript.Run(ctx, b.rdb, keys, event.Type, string(event.Data), replaySize, int(replayTTL.Seconds())).Err()
+}
+
+func (b *RedisBroker) Signal(ctx context.Context, userID uuid.UUID, eventType string, data any) error {
+	event, err := newEvent(0, eventType, data)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return b.rdb.Publish(ctx, channelName(userID), payload).Err()
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context, userID uuid.UUID, lastEventID uint64) (<-chan Event, error) {
+	pubsub := b.rdb.Subscribe(ctx, channelName(userID))
+
+	// Wait for the subscription before reading the replay buffer, so no event
+	// published in between is lost. Live events already replayed are skipped
+	// by ID below.
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	buffered, err := b.rdb.LRange(ctx, replayKey(userID), 0, -1).Result()
+	if err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	var missed []Event
+	for i := len(buffered) - 1; i >= 0; i-- {
+		var event Event
+		if err := json.Unmarshal([]byte(buffered[i]), &event); err != nil {
+			continue
+		}
+
+		if event.ID > lastEventID {
+			missed = append(missed, event)
+		}
+	}
+
+	var replayed uint64
+	ch := make(chan Event, len(missed)+subscriberBuffer)
+	for _, event := range missed {
+		ch <- event
+		replayed = max(replayed, event.ID)
+	}
+
+	go func() {
+		defer close(ch)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				var event Event
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					continue
+				}
+
+				if event.ID != 0 && event.ID <= replayed {
+					continue
+				}
+
+				select {
+				case ch <- event:
+				default:
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func seqKey(userID uuid.UUID) string {
+	return fmt.Sprintf("events-seq-%v", userID)
+}
+
+func replayKey(userID uuid.UUID) string {
+	return fmt.Sprintf("events-replay-%v", userID)
+}
+
+func channelName(userID uuid.UUID) string {
+	return fmt.Sprintf("events-%v", userID)
+}
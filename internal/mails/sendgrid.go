@@ -18,12 +18,13 @@ type SendGridMailer struct {
 }
 
 const (
-	FromName           = "GoSocial"
-	maxRetry           = 3
-	ActivationTemplate = "activation_mail.templ"
+	FromName              = "GoSocial"
+	maxRetry              = 3
+	ActivationTemplate    = "activation_mail.templ"
+	PasswordResetTemplate = "password_reset_mail.templ"
 )
 
-//go:embed templates/activation_mail.templ
+//go:embed templates/*.templ
 var FS embed.FS
 
 func NewMailer(apiKey, fromEmail string) *SendGridMailer {
@@ -0,0 +1,110 @@
+package media
+
+import (
+	"image"
+	"math"
+	"strings"
+)
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// BlurHash encodes a compact placeholder for img, see https://blurha.sh. It
+// uses four components along the longer side and three along the shorter one,
+// so img should already be small.
+func BlurHash(img *image.RGBA) string {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+
+	cx, cy := 4, 3
+	if h > w {
+		cx, cy = 3, 4
+	}
+
+	factors := make([][3]float64, 0, cx*cy)
+	for j := 0; j < cy; j++ {
+		for i := 0; i < cx; i++ {
+			factors = append(factors, blurHashFactor(img, w, h, i, j))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(encode83((cx-1)+(cy-1)*9, 1))
+
+	dc, ac := factors[0], factors[1:]
+
+	maxValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+
+		quantisedMax := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantisedMax+1) / 166
+		b.WriteString(encode83(quantisedMax, 1))
+	} else {
+		b.WriteString(encode83(0, 1))
+	}
+
+	b.WriteString(encode83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4))
+
+	for _, f := range ac {
+		quant := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+		}
+		b.WriteString(encode83(quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2))
+	}
+
+	return b.String()
+}
+
+func blurHashFactor(img *image.RGBA, w, h, i, j int) [3]float64 {
+	var r, g, b float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(w)) *
+				math.Cos(math.Pi*float64(j)*float64(y)/float64(h))
+
+			p := img.PixOffset(x, y)
+			r += basis * sRGBToLinear(img.Pix[p])
+			g += basis * sRGBToLinear(img.Pix[p+1])
+			b += basis * sRGBToLinear(img.Pix[p+2])
+		}
+	}
+
+	normalisation := 2.0
+	if i == 0 && j == 0 {
+		normalisation = 1
+	}
+	scale := normalisation / float64(w*h)
+
+	return [3]float64{r * scale, g * scale, b * scale}
+}
+
+func encode83(value, length int) string {
+	out := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		out[i] = base83Chars[value%83]
+		value /= 83
+	}
+	return string(out)
+}
+
+func sRGBToLinear(v uint8) float64 {
+	x := float64(v) / 255
+	if x <= 0.04045 {
+		return x / 12.92
+	}
+	return math.Pow((x+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	x := math.Max(0, math.Min(1, v))
+	if x <= 0.0031308 {
+		return int(x*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(x, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
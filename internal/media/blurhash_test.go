@@ -0,0 +1,72 @@
+package media
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBlurHash(t *testing.T) {
+	fill := func(c color.RGBA) func(x, y int) color.RGBA {
+		return func(x, y int) color.RGBA { return c }
+	}
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	blue := color.RGBA{B: 0xFF, A: 0xFF}
+
+	// Apart from the well-known hash of a black image, the expected hashes
+	// come from the reference encoder of https://github.com/woltapp/blurhash.
+	tests := []struct {
+		name  string
+		w, h  int
+		pixel func(x, y int) color.RGBA
+		want  string
+	}{
+		{"black", 32, 24, fill(color.RGBA{A: 0xFF}), "L00000fQfQfQfQfQfQfQfQfQfQfQ"},
+		{"black portrait", 24, 32, fill(color.RGBA{A: 0xFF}), "T00000fQfQfQfQfQfQfQfQfQfQfQ"},
+		{"red", 32, 24, fill(red), "LDTI:j]9fQ]9|co1fQo1fQfQfQfQ"},
+		{"white", 32, 24, fill(color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}), "LDTSUA_3fQ_3~qoffQoffQfQfQfQ"},
+		{"red and blue halves", 32, 24, func(x, y int) color.RGBA {
+			if x < 16 {
+				return red
+			}
+			return blue
+		}, "L~LjfL|TsRJrsXn~jsa}fQfQfQfQ"},
+		{"gradient portrait", 24, 32, func(x, y int) color.RGBA {
+			return color.RGBA{R: uint8(x * 8), G: uint8(y * 10), B: 128, A: 0xFF}
+		}, "TZCtk37KwxmHagjtdLe;fQq8bZjt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.NewRGBA(image.Rect(0, 0, tt.w, tt.h))
+			for y := 0; y < tt.h; y++ {
+				for x := 0; x < tt.w; x++ {
+					img.SetRGBA(x, y, tt.pixel(x, y))
+				}
+			}
+
+			if got := BlurHash(img); got != tt.want {
+				t.Errorf("BlurHash = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncode83(t *testing.T) {
+	tests := []struct {
+		value, length int
+		want          string
+	}{
+		{0, 1, "0"},
+		{82, 1, "~"},
+		{83, 2, "10"},
+		{3429, 2, "fQ"},
+		{0xFF0000, 4, "TI:j"},
+	}
+
+	for _, tt := range tests {
+		if got := encode83(tt.value, tt.length); got != tt.want {
+			t.Errorf("encode83(%d, %d) = %q, want %q", tt.value, tt.length, got, tt.want)
+		}
+	}
+}
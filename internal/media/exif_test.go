@@ -0,0 +1,123 @@
+package media
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// exifTIFF builds TIFF data whose first IFD holds a single orientation entry.
+func exifTIFF(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 26)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], exifOrientationTag)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+
+	return tiff
+}
+
+// exifJPEG wraps TIFF data in the APP1 segment of a minimal JPEG header.
+func exifJPEG(tiff []byte) []byte {
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	data = binary.BigEndian.AppendUint16(data, uint16(len(segment)+2))
+	data = append(data, segment...)
+
+	return append(data, 0xFF, 0xDA, 0x00, 0x02)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"big endian", exifJPEG(exifTIFF(binary.BigEndian, 6)), 6},
+		{"little endian", exifJPEG(exifTIFF(binary.LittleEndian, 8)), 8},
+		{"out of range", exifJPEG(exifTIFF(binary.BigEndian, 9)), 1},
+		{"no exif", []byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02}, 1},
+		{"not a jpeg", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"empty", nil, 1},
+		{"segment past the end", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF, 0xFF, 'E'}, 1},
+		{"segment length too short", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x01, 0xFF, 0xDA}, 1},
+		{"missing marker", []byte{0xFF, 0xD8, 0x00, 0xE1, 0x00, 0x02}, 1},
+		{"exif without tiff", exifJPEG(nil), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Errorf("jpegOrientation = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJPEGOrientationTruncated(t *testing.T) {
+	data := exifJPEG(exifTIFF(binary.LittleEndian, 6))
+
+	for n := range data {
+		if got := jpegOrientation(data[:n]); got != 1 && got != 6 {
+			t.Errorf("jpegOrientation of the first %d bytes = %d, want 1 or 6", n, got)
+		}
+	}
+}
+
+func TestTIFFOrientation(t *testing.T) {
+	badMagic := exifTIFF(binary.BigEndian, 6)
+	badMagic[3] = 43
+
+	ifdPastEnd := exifTIFF(binary.LittleEndian, 6)
+	binary.LittleEndian.PutUint32(ifdPastEnd[4:], 1<<31-1)
+
+	ifdInHeader := exifTIFF(binary.LittleEndian, 6)
+	binary.LittleEndian.PutUint32(ifdInHeader[4:], 2)
+
+	tooManyEntries := exifTIFF(binary.BigEndian, 6)
+	binary.BigEndian.PutUint16(tooManyEntries[10:], 0x0100)
+	binary.BigEndian.PutUint16(tooManyEntries[8:], 0xFFFF)
+
+	tests := []struct {
+		name string
+		tiff []byte
+		want int
+	}{
+		{"upright", exifTIFF(binary.BigEndian, 1), 1},
+		{"mirrored", exifTIFF(binary.LittleEndian, 2), 2},
+		{"zero", exifTIFF(binary.LittleEndian, 0), 1},
+		{"unknown byte order", append([]byte("XX"), exifTIFF(binary.BigEndian, 6)[2:]...), 1},
+		{"bad magic", badMagic, 1},
+		{"ifd past the end", ifdPastEnd, 1},
+		{"ifd inside the header", ifdInHeader, 1},
+		{"entries past the end", tooManyEntries, 1},
+		{"too short", []byte("MM\x00"), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tiffOrientation(tt.tiff); got != tt.want {
+				t.Errorf("tiffOrientation = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTIFFOrientationTruncated(t *testing.T) {
+	tiff := exifTIFF(binary.BigEndian, 3)
+
+	// The entry ends at byte 22; every shorter prefix lacks the tag.
+	for n := 0; n < 22; n++ {
+		if got := tiffOrientation(tiff[:n]); got != 1 {
+			t.Errorf("tiffOrientation of the first %d bytes = %d, want 1", n, got)
+		}
+	}
+}
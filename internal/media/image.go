@@ -0,0 +1,192 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// MaxImagePixels bounds the decoded size of uploads, so small files cannot
+// expand into huge bitmaps. Processing holds up to three bitmaps of the image
+// at once (decoded, RGBA and oriented), about 300 MB at this size.
+const MaxImagePixels = 25_000_000
+
+const jpegQuality = 85
+
+// blurHashSize is the longest side of the copy the BlurHash is computed from.
+const blurHashSize = 32
+
+var ErrUnsupportedImage = errors.New("unsupported or corrupt image")
+
+// Image is an encoded image ready to be stored.
+type Image struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// Variant is a downscaled copy of an image, named after its longest side.
+type Variant struct {
+	Name string
+	Image
+}
+
+// ProcessedImage is the result of ProcessImage.
+type ProcessedImage struct {
+	Original Image
+	Variants []Variant
+	BlurHash string
+}
+
+// ProcessImage makes an upload safe to serve: it is decoded, turned upright
+// according to its EXIF orientation and re-encoded, which drops all metadata.
+// It also renders a variant for every size smaller than the image and a
+// BlurHash placeholder. Animated GIFs keep only their first frame.
+func ProcessImage(data []byte, sizes []int) (*ProcessedImage, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+
+	if cfg.Width*cfg.Height > MaxImagePixels {
+		return nil, fmt.Errorf("%w: %dx%d pixels is too large", ErrUnsupportedImage, cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+
+	img := toRGBA(src)
+	if format == "jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+
+	// Keep transparency where the source may have it; everything else is
+	// served as JPEG.
+	lossless := format == "png" || format == "gif" || !img.Opaque()
+
+	original, err := encode(img, lossless)
+	if err != nil {
+		return nil, err
+	}
+
+	processed := &ProcessedImage{
+		Original: original,
+		BlurHash: BlurHash(scaleToFit(img, blurHashSize)),
+	}
+
+	longest := max(img.Bounds().Dx(), img.Bounds().Dy())
+	for _, size := range sizes {
+		if size >= longest {
+			continue
+		}
+
+		variant, err := encode(scaleToFit(img, size), lossless)
+		if err != nil {
+			return nil, err
+		}
+
+		processed.Variants = append(processed.Variants, Variant{
+			Name:  fmt.Sprintf("%d", size),
+			Image: variant,
+		})
+	}
+
+	return processed, nil
+}
+
+func encode(img *image.RGBA, lossless bool) (Image, error) {
+	var buf bytes.Buffer
+	out := Image{
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}
+
+	if lossless {
+		out.ContentType = "image/png"
+		if err := png.Encode(&buf, img); err != nil {
+			return Image{}, err
+		}
+	} else {
+		out.ContentType = "image/jpeg"
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return Image{}, err
+		}
+	}
+
+	out.Data = buf.Bytes()
+	return out, nil
+}
+
+// scaleToFit returns a copy of img whose longest side is size pixels.
+func scaleToFit(img *image.RGBA, size int) *image.RGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w >= h {
+		h = max(1, h*size/w)
+		w = size
+	} else {
+		w = max(1, w*size/h)
+		h = size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// orient turns an image upright according to an EXIF orientation value.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+
+			si := img.PixOffset(sx, sy)
+			di := dst.PixOffset(x, y)
+			copy(dst.Pix[di:di+4], img.Pix[si:si+4])
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,187 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestOrient(t *testing.T) {
+	// The source image, three pixels wide and two high:
+	//
+	//	a b c
+	//	d e f
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for i, label := range "abcdef" {
+		src.Pix[i*4] = byte(label)
+		src.Pix[i*4+3] = 0xFF
+	}
+
+	tests := []struct {
+		orientation int
+		want        []string
+	}{
+		{1, []string{"abc", "def"}},
+		{2, []string{"cba", "fed"}},
+		{3, []string{"fed", "cba"}},
+		{4, []string{"def", "abc"}},
+		{5, []string{"ad", "be", "cf"}},
+		{6, []string{"da", "eb", "fc"}},
+		{7, []string{"fc", "eb", "da"}},
+		{8, []string{"cf", "be", "ad"}},
+		{9, []string{"abc", "def"}},
+	}
+
+	for _, tt := range tests {
+		got := orient(src, tt.orientation)
+
+		w, h := got.Bounds().Dx(), got.Bounds().Dy()
+		if h != len(tt.want) || w != len(tt.want[0]) {
+			t.Errorf("orientation %d: size %dx%d, want %dx%d", tt.orientation, w, h, len(tt.want[0]), len(tt.want))
+			continue
+		}
+
+		for y, row := range tt.want {
+			for x := range row {
+				if label := got.Pix[got.PixOffset(x, y)]; label != row[x] {
+					t.Errorf("orientation %d: pixel (%d, %d) = %c, want %c", tt.orientation, x, y, label, row[x])
+				}
+			}
+		}
+	}
+}
+
+// halvesImage is red on its left half and blue on its right half.
+func halvesImage(w, h int, alpha uint8) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{B: alpha, A: alpha}
+			if x < w/2 {
+				c = color.RGBA{R: alpha, A: alpha}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	return img
+}
+
+func TestProcessImageJPEG(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, halvesImage(40, 20, 0xFF), &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Put an EXIF segment asking for a quarter turn clockwise in front of the
+	// encoded image, in place of its start of image marker.
+	exif := exifJPEG(exifTIFF(binary.BigEndian, 6))
+	data := append(exif[:len(exif)-4:len(exif)-4], encoded.Bytes()[2:]...)
+
+	processed, err := ProcessImage(data, []int{10, 16, 40, 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := processed.Original
+	if original.ContentType != "image/jpeg" {
+		t.Errorf("content type = %q, want image/jpeg", original.ContentType)
+	}
+	if original.Width != 20 || original.Height != 40 {
+		t.Errorf("size = %dx%d, want the upright 20x40", original.Width, original.Height)
+	}
+	if bytes.Contains(original.Data, []byte("Exif")) {
+		t.Error("the processed image still carries EXIF metadata")
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(original.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Turned clockwise, the red left half ends up on top.
+	if r, _, b, _ := img.At(10, 5).RGBA(); r < b {
+		t.Error("the top of the upright image is not red")
+	}
+	if r, _, b, _ := img.At(10, 35).RGBA(); r > b {
+		t.Error("the bottom of the upright image is not blue")
+	}
+
+	// Sizes from the longest side up are skipped.
+	want := []struct {
+		name          string
+		width, height int
+	}{
+		{"10", 5, 10},
+		{"16", 8, 16},
+	}
+	if len(processed.Variants) != len(want) {
+		t.Fatalf("got %d variants, want %d", len(processed.Variants), len(want))
+	}
+	for i, w := range want {
+		v := processed.Variants[i]
+		if v.Name != w.name || v.Width != w.width || v.Height != w.height || v.ContentType != "image/jpeg" {
+			t.Errorf("variant %d = %s %dx%d %s, want %s %dx%d image/jpeg", i, v.Name, v.Width, v.Height, v.ContentType, w.name, w.width, w.height)
+		}
+	}
+
+	if processed.BlurHash == "" {
+		t.Error("no BlurHash")
+	}
+}
+
+func TestProcessImagePNGWithAlpha(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, halvesImage(30, 30, 0x80)); err != nil {
+		t.Fatal(err)
+	}
+
+	processed, err := ProcessImage(encoded.Bytes(), []int{15})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if processed.Original.ContentType != "image/png" {
+		t.Errorf("content type = %q, want image/png", processed.Original.ContentType)
+	}
+	if len(processed.Variants) != 1 || processed.Variants[0].ContentType != "image/png" {
+		t.Errorf("variants = %+v, want one PNG", processed.Variants)
+	}
+
+	img, err := png.Decode(bytes.NewReader(processed.Original.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, a := img.At(0, 0).RGBA(); a == 0xFFFF {
+		t.Error("transparency was lost")
+	}
+}
+
+func TestProcessImageRejects(t *testing.T) {
+	// A GIF header announcing 6000x6000 pixels, past MaxImagePixels.
+	huge := []byte("GIF89a")
+	huge = binary.LittleEndian.AppendUint16(huge, 6000)
+	huge = binary.LittleEndian.AppendUint16(huge, 6000)
+	huge = append(huge, 0, 0, 0, ';')
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"too many pixels", huge},
+		{"not an image", []byte("hello, world")},
+		{"truncated", []byte{0xFF, 0xD8, 0xFF}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ProcessImage(tt.data, []int{160}); !errors.Is(err, ErrUnsupportedImage) {
+				t.Errorf("ProcessImage error = %v, want ErrUnsupportedImage", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+//go:build integration
+
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestS3StorageMinIO runs the storage against the MinIO of docker-compose.yaml:
+//
+//	docker compose up -d minio
+//	go test -tags integration ./internal/media
+func TestS3StorageMinIO(t *testing.T) {
+	cfg := S3Config{
+		Endpoint:  envOr("S3_ENDPOINT", "http://localhost:9000"),
+		Bucket:    "go-social-test-" + uuid.NewString()[:8],
+		Region:    "us-east-1",
+		AccessKey: envOr("S3_ACCESS_KEY", "minioadmin"),
+		SecretKey: envOr("S3_SECRET_KEY", "minioadmin"),
+		PathStyle: true,
+	}
+
+	s, err := NewS3Storage(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	createBucket(t, s)
+	t.Cleanup(func() { bucketRequest(t, s, http.MethodDelete) })
+
+	key := "post/" + uuid.NewString() + "/image name.png"
+	data := []byte("not really a png")
+
+	if err := s.Put(ctx, key, data, "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	object, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, err := io.ReadAll(object)
+	object.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("Get = %q, want %q", got, data)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of a missing object = %v, want nil", err)
+	}
+}
+
+func createBucket(t *testing.T, s *S3Storage) {
+	t.Helper()
+
+	if status := bucketRequest(t, s, http.MethodPut); status != http.StatusOK {
+		t.Fatalf("creating bucket %s failed with status %d", s.bucket, status)
+	}
+}
+
+// bucketRequest sends a signed request for the bucket itself and returns the
+// response status.
+func bucketRequest(t *testing.T, s *S3Storage, method string) int {
+	t.Helper()
+
+	u := *s.endpoint
+	u.Path = "/" + s.bucket
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.sign(req, nil, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		t.Fatalf("S3 endpoint unreachable: %v", err)
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
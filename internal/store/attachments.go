@@ -0,0 +1,400 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+const MaxPostAttachments = 4
+
+const (
+	AttachmentKindPost   = "post"
+	AttachmentKindAvatar = "avatar"
+)
+
+const (
+	AttachmentStatusProcessing = "processing"
+	AttachmentStatusReady      = "ready"
+	AttachmentStatusFailed     = "failed"
+)
+
+var ErrInvalidAttachments = errors.New("attachments must be your own unused uploads")
+
+// Attachment is an uploaded media object. The object itself lives in the media
+// storage under StorageKey; URL is a signed link filled in by the API. Uploads
+// are only served once processing made them ready.
+type Attachment struct {
+	ID          int64     `json:"id" db:"id"`
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
+	PostID      *int64    `json:"post_id,omitempty" db:"post_id"`
+	Kind        string    `json:"kind" db:"kind"`
+	StorageKey  string    `json:"-" db:"storage_key"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	SizeBytes   int64     `json:"size_bytes" db:"size_bytes"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	Status      string    `json:"status" db:"status"`
+	Width       int       `json:"width,omitempty" db:"width"`
+	Height      int       `json:"height,omitempty" db:"height"`
+	BlurHash    string    `json:"blurhash,omitempty" db:"blurhash"`
+	Attempts    int       `json:"-" db:"attempts"`
+	URL         string    `json:"url,omitempty" db:"-"`
+
+	Variants []AttachmentVariant `json:"variants" db:"-"`
+}
+
+// AttachmentVariant is a downscaled copy of an image attachment.
+type AttachmentVariant struct {
+	AttachmentID int64  `json:"-" db:"attachment_id"`
+	Name         string `json:"name" db:"name"`
+	StorageKey   string `json:"-" db:"storage_key"`
+	ContentType  string `json:"content_type" db:"content_type"`
+	Width        int    `json:"width" db:"width"`
+	Height       int    `json:"height" db:"height"`
+	SizeBytes    int64  `json:"size_bytes" db:"size_bytes"`
+	URL          string `json:"url" db:"-"`
+}
+
+const attachmentColumns = `id, user_id, post_id, kind, storage_key, content_type, size_bytes, created_at, status, width, height, blurhash, attempts`
+
+const variantColumns = `attachment_id, name, storage_key, content_type, width, height, size_bytes`
+
+type AttachmentsStore struct {
+	db *sqlx.DB
+}
+
+func NewAttachmentsStore(db *sql.DB) *AttachmentsStore {
+	return &AttachmentsStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+func (s *AttachmentsStore) Create(ctx context.Context, a *Attachment) error {
+	const query = `INSERT INTO attachments (user_id, kind, storage_key, content_type, size_bytes)
+				   VALUES ($1, $2, $3, $4, $5)
+				   RETURNING ` + attachmentColumns + `;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	a.Variants = []AttachmentVariant{}
+
+	return s.db.GetContext(ctx, a, query, a.UserID, a.Kind, a.StorageKey, a.ContentType, a.SizeBytes)
+}
+
+func (s *AttachmentsStore) GetByID(ctx context.Context, id int64) (*Attachment, error) {
+	const query = `SELECT ` + attachmentColumns + ` FROM attachments WHERE id = $1;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var a Attachment
+	if err := s.db.GetContext(ctx, &a, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if err := loadVariants(ctx, s.db, []*Attachment{&a}); err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// ClaimForProcessing hands out up to limit uploads waiting for processing.
+// Claims expire after lease, so uploads of a worker that died are picked up
+// again; uploads claimed maxAttempts times are given up on.
+func (s *AttachmentsStore) ClaimForProcessing(ctx context.Context, limit int, lease time.Duration, maxAttempts int) ([]Attachment, error) {
+	claimable := time.Now().Add(-lease)
+	claimed := []Attachment{}
+
+	err := withTx(s.db, ctx, func(tx *sqlx.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		const abandon = `UPDATE attachments SET status = 'failed'
+						 WHERE status = 'processing' AND attempts >= $1 AND processing_started_at < $2;`
+		if _, err := tx.ExecContext(ctx, abandon, maxAttempts, claimable); err != nil {
+			return err
+		}
+
+		const query = `UPDATE attachments SET attempts = attempts + 1, processing_started_at = NOW()
+					   WHERE id IN (
+					       SELECT id FROM attachments
+					       WHERE status = 'processing' AND attempts < $2
+					         AND (processing_started_at IS NULL OR processing_started_at < $3)
+					       ORDER BY created_at
+					       LIMIT $1
+					       FOR UPDATE SKIP LOCKED
+					   )
+					   RETURNING ` + attachmentColumns + `;`
+
+		return tx.SelectContext(ctx, &claimed, query, limit, maxAttempts, claimable)
+	})
+
+	return claimed, err
+}
+
+// CompleteProcessing stores the processed form of an upload and its variants
+// and marks it ready.
+func (s *AttachmentsStore) CompleteProcessing(ctx context.Context, a *Attachment) error {
+	return withTx(s.db, ctx, func(tx *sqlx.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		const query = `UPDATE attachments
+					   SET status = 'ready', content_type = $2, size_bytes = $3, width = $4, height = $5, blurhash = $6
+					   WHERE id = $1;`
+		if _, err := tx.ExecContext(ctx, query, a.ID, a.ContentType, a.SizeBytes, a.Width, a.Height, a.BlurHash); err != nil {
+			return err
+		}
+
+		const variantQuery = `INSERT INTO attachment_variants (` + variantColumns + `)
+							  VALUES (:attachment_id, :name, :storage_key, :content_type, :width, :height, :size_bytes)
+							  ON CONFLICT (attachment_id, name) DO UPDATE
+							  SET storage_key = EXCLUDED.storage_key, content_type = EXCLUDED.content_type,
+							      width = EXCLUDED.width, height = EXCLUDED.height, size_bytes = EXCLUDED.size_bytes;`
+		for i := range a.Variants {
+			a.Variants[i].AttachmentID = a.ID
+			if _, err := tx.NamedExecContext(ctx, variantQuery, a.Variants[i]); err != nil {
+				return err
+			}
+		}
+
+		a.Status = AttachmentStatusReady
+		return nil
+	})
+}
+
+// FailProcessing marks an upload that cannot be processed as failed.
+func (s *AttachmentsStore) FailProcessing(ctx context.Context, id int64) error {
+	const query = `UPDATE attachments SET status = 'failed' WHERE id = $1;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// SetAvatar makes an avatar upload of the user their avatar, or clears the
+// avatar when attachmentID is nil. The replaced upload is left for the orphan
+// cleanup.
+func (s *AttachmentsStore) SetAvatar(ctx context.Context, userID uuid.UUID, attachmentID *int64) error {
+	const query = `UPDATE users SET avatar_id = $2 WHERE id = $1;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, attachmentID)
+	return err
+}
+
+// DeleteOrphans removes up to limit uploads created before the given time that
+// belong to no post and are nobody's avatar: uploads that were never used, the
+// media of purged posts and replaced avatars. It returns the storage keys of the
+// removed uploads and their variants so the objects can be deleted.
+func (s *AttachmentsStore) DeleteOrphans(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	const orphaned = `a.post_id IS NULL AND a.created_at < $1 AND NOT EXISTS (SELECT 1 FROM users u WHERE u.avatar_id = a.id)`
+
+	// The variants go with their attachment; both statements see the variants
+	// as they were before.
+	const query = `WITH deleted AS (
+				       DELETE FROM attachments a
+				       WHERE a.id IN (
+				           SELECT a.id FROM attachments a WHERE ` + orphaned + `
+				           ORDER BY a.created_at
+				           LIMIT $2
+				           FOR UPDATE SKIP LOCKED
+				       ) AND ` + orphaned + `
+				       RETURNING a.id, a.storage_key
+				   )
+				   SELECT storage_key FROM deleted
+				   UNION ALL
+				   SELECT v.storage_key FROM attachment_variants v JOIN deleted d ON d.id = v.attachment_id;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	keys := []string{}
+	if err := s.db.SelectContext(ctx, &keys, query, before, limit); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// linkAttachments assigns the uploads listed in post.Attachments to the post and
+// replaces them with the stored rows. Every upload must be an unused post upload
+// of the post's author.
+func linkAttachments(ctx context.Context, tx *sqlx.Tx, post *Post) error {
+	if len(post.Attachments) == 0 {
+		post.Attachments = []Attachment{}
+		return nil
+	}
+
+	ids := make([]int64, len(post.Attachments))
+	for i, a := range post.Attachments {
+		ids[i] = a.ID
+	}
+
+	const query = `UPDATE attachments SET post_id = $1, position = array_position($2, id)
+				   WHERE id = ANY($2) AND user_id = $3 AND kind = 'post' AND post_id IS NULL
+				   RETURNING ` + attachmentColumns + `;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	linked := []Attachment{}
+	if err := tx.SelectContext(ctx, &linked, query, post.ID, pq.Array(ids), post.UserID); err != nil {
+		return err
+	}
+
+	if len(linked) != len(ids) {
+		return ErrInvalidAttachments
+	}
+
+	post.Attachments = sortAttachments(linked, ids)
+
+	refs := make([]*Attachment, len(post.Attachments))
+	for i := range post.Attachments {
+		refs[i] = &post.Attachments[i]
+	}
+
+	if err := loadVariants(ctx, tx, refs); err != nil {
+		return err
+	}
+
+	post.MediaStatus = mediaStatus(post.Attachments)
+
+	return nil
+}
+
+// attachMedia loads the attachments of posts, and of the originals embedded in
+// them, in one query.
+func attachMedia(ctx context.Context, db *sqlx.DB, posts []*Post) error {
+	byID := make(map[int64][]*Post)
+	var ids []int64
+	for _, p := range posts {
+		for _, q := range []*Post{p, p.Original} {
+			if q == nil {
+				continue
+			}
+			q.Attachments = []Attachment{}
+			if _, ok := byID[q.ID]; !ok {
+				ids = append(ids, q.ID)
+			}
+			byID[q.ID] = append(byID[q.ID], q)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	const query = `SELECT ` + attachmentColumns + ` FROM attachments WHERE post_id = ANY($1) ORDER BY post_id, position;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var attachments []Attachment
+	if err := db.SelectContext(ctx, &attachments, query, pq.Array(ids)); err != nil {
+		return err
+	}
+
+	refs := make([]*Attachment, len(attachments))
+	for i := range attachments {
+		refs[i] = &attachments[i]
+	}
+
+	if err := loadVariants(ctx, db, refs); err != nil {
+		return err
+	}
+
+	for _, a := range attachments {
+		for _, p := range byID[*a.PostID] {
+			p.Attachments = append(p.Attachments, a)
+		}
+	}
+
+	for _, ps := range byID {
+		for _, p := range ps {
+			p.MediaStatus = mediaStatus(p.Attachments)
+		}
+	}
+
+	return nil
+}
+
+// loadVariants fills in the variants of attachments, smallest first.
+func loadVariants(ctx context.Context, db sqlx.QueryerContext, attachments []*Attachment) error {
+	ids := make([]int64, 0, len(attachments))
+	byID := make(map[int64]*Attachment, len(attachments))
+	for _, a := range attachments {
+		a.Variants = []AttachmentVariant{}
+		ids = append(ids, a.ID)
+		byID[a.ID] = a
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	const query = `SELECT ` + variantColumns + ` FROM attachment_variants WHERE attachment_id = ANY($1) ORDER BY attachment_id, width * height;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var variants []AttachmentVariant
+	if err := sqlx.SelectContext(ctx, db, &variants, query, pq.Array(ids)); err != nil {
+		return err
+	}
+
+	for _, v := range variants {
+		a := byID[v.AttachmentID]
+		a.Variants = append(a.Variants, v)
+	}
+
+	return nil
+}
+
+// mediaStatus sums up the processing state of the attachments of a post: it is
+// processing while any of them is, and empty without attachments.
+func mediaStatus(attachments []Attachment) string {
+	if len(attachments) == 0 {
+		return ""
+	}
+
+	status := AttachmentStatusReady
+	for _, a := range attachments {
+		switch a.Status {
+		case AttachmentStatusProcessing:
+			return AttachmentStatusProcessing
+		case AttachmentStatusFailed:
+			status = AttachmentStatusFailed
+		}
+	}
+
+	return status
+}
+
+// sortAttachments orders attachments the way their IDs were given.
+func sortAttachments(attachments []Attachment, ids []int64) []Attachment {
+	byID := make(map[int64]Attachment, len(attachments))
+	for _, a := range attachments {
+		byID[a.ID] = a
+	}
+
+	sorted := make([]Attachment, 0, len(ids))
+	for _, id := range ids {
+		sorted = append(sorted, byID[id])
+	}
+
+	return sorted
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+// RelatedUser is a user in a block or mute list.
+type RelatedUser struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Username  string    `json:"username" db:"username"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type BlocksStore struct {
+	db *sqlx.DB
+}
+
+func NewBlocksStore(db *sql.DB) *BlocksStore {
+	return &BlocksStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// Block blocks a user and drops every follow and pending follow request
+// between the two users, in both directions.
+func (s *BlocksStore) Block(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sqlx.Tx) error {
+		const block = `INSERT INTO blocks (blocker_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;`
+
+		if _, err := tx.ExecContext(ctx, block, blockerID, blockedID); err != nil {
+			return err
+		}
+
+		const unfollow = `
+DELETE FROM followers
+WHERE (user_id = $1 AND follower_id = $2) OR (user_id = $2 AND follower_id = $1);
+`
+
+		if _, err := tx.ExecContext(ctx, unfollow, blockerID, blockedID); err != nil {
+			return err
+		}
+
+		const dropRequests = `
+DELETE FROM follow_requests
+WHERE (requester_id = $1 AND target_id = $2) OR (requester_id = $2 AND target_id = $1);
+`
+
+		_, err := tx.ExecContext(ctx, dropRequests, blockerID, blockedID)
+		return err
+	})
+}
+
+func (s *BlocksStore) Unblock(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	const query = `DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, blockerID, blockedID)
+	return err
+}
+
+// IsBlocked reports whether either user blocked the other.
+func (s *BlocksStore) IsBlocked(ctx context.Context, userID, otherID uuid.UUID) (bool, error) {
+	const query = `
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+);
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var blocked bool
+	err := s.db.GetContext(ctx, &blocked, query, userID, otherID)
+	return blocked, err
+}
+
+// List returns the users blocked by the user, most recent first unless fq asks
+// for ascending order.
+func (s *BlocksStore) List(ctx context.Context, user *User, fq PaginatedQuery) ([]RelatedUser, Page, error) {
+	return listRelated(ctx, s.db, "blocks", "blocker_id", "blocked_id", user, fq)
+}
+
+type MutesStore struct {
+	db *sqlx.DB
+}
+
+func NewMutesStore(db *sql.DB) *MutesStore {
+	return &MutesStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// Mute hides a user's posts from the muter's feed without unfollowing them.
+func (s *MutesStore) Mute(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	const query = `INSERT INTO mutes (muter_id, muted_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, muterID, mutedID)
+	return err
+}
+
+func (s *MutesStore) Unmute(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	const query = `DELETE FROM mutes WHERE muter_id = $1 AND muted_id = $2;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, muterID, mutedID)
+	return err
+}
+
+// List returns the users muted by the user, most recent first unless fq asks
+// for ascending order.
+func (s *MutesStore) List(ctx context.Context, user *User, fq PaginatedQuery) ([]RelatedUser, Page, error) {
+	return listRelated(ctx, s.db, "mutes", "muter_id", "muted_id", user, fq)
+}
+
+// listRelated pages through the rows of a user relation table owned by user.
+func listRelated(ctx context.Context, db *sqlx.DB, table, ownerCol, relatedCol string, user *User, fq PaginatedQuery) ([]RelatedUser, Page, error) {
+	keyset, order, keysetArgs, err := fq.keyset("r.created_at", "r."+relatedCol, uuidCursorID, 3)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	query := `
+SELECT u.id, u.username, r.created_at
+FROM ` + table + ` r
+JOIN users u ON u.id = r.` + relatedCol + `
+WHERE r.` + ownerCol + ` = $1 AND ` + keyset + `
+ORDER BY ` + order + `
+LIMIT $2;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := append([]any{user.ID, fq.Limit + 1}, keysetArgs...)
+
+	related := []RelatedUser{}
+	if err := db.SelectContext(ctx, &related, query, args...); err != nil {
+		return nil, Page{}, err
+	}
+
+	related, page := paginate(related, fq, relatedCursor)
+
+	return related, page, nil
+}
+
+func relatedCursor(u RelatedUser) Cursor {
+	return Cursor{CreatedAt: u.CreatedAt, ID: u.ID.String()}
+}
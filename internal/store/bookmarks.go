@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"strconv"
+	"time"
+)
+
+type BookmarkedPost struct {
+	PostMetadata
+	BookmarkedAt time.Time `json:"bookmarked_at" db:"bookmarked_at"`
+}
+
+type BookmarksStore struct {
+	db *sqlx.DB
+}
+
+func NewBookmarksStore(db *sql.DB) *BookmarksStore {
+	return &BookmarksStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+func (s *BookmarksStore) Add(ctx context.Context, userID uuid.UUID, postID int64) error {
+	const query = `INSERT INTO bookmarks (user_id, post_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, postID)
+	return err
+}
+
+func (s *BookmarksStore) Remove(ctx context.Context, userID uuid.UUID, postID int64) error {
+	const query = `DELETE FROM bookmarks WHERE user_id = $1 AND post_id = $2;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, postID)
+	return err
+}
+
+// List returns the posts saved by the user, most recently bookmarked first unless
+// fq asks for ascending order.
+func (s *BookmarksStore) List(ctx context.Context, user *User, fq PaginatedQuery) ([]BookmarkedPost, Page, error) {
+	keyset, order, keysetArgs, err := fq.keyset("b.created_at", "b.post_id", bigintCursorID, 5)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	query := `
+SELECT ` + postMetadataColumns + `,
+    b.created_at AS bookmarked_at
+FROM bookmarks b
+JOIN posts p ON p.id = b.post_id
+JOIN users u ON u.id = p.user_id
+WHERE
+    b.user_id = $1 AND
+    ` + livePostClause + ` AND
+    ` + visibleAuthorClause + ` AND
+    ` + postFilters + ` AND
+    ` + keyset + `
+ORDER BY ` + order + `
+LIMIT $2;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := append([]any{user.ID, fq.Limit + 1, fq.Search, pq.Array(fq.Tags)}, keysetArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	defer rows.Close()
+
+	bookmarks := []BookmarkedPost{}
+	for rows.Next() {
+		var b BookmarkedPost
+		if err := scanPostMetadata(rows, &b.PostMetadata, &b.BookmarkedAt); err != nil {
+			return nil, Page{}, err
+		}
+
+		bookmarks = append(bookmarks, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Page{}, err
+	}
+
+	bookmarks, page := paginate(bookmarks, fq, bookmarkCursor)
+
+	posts := make([]*Post, len(bookmarks))
+	for i := range bookmarks {
+		posts[i] = &bookmarks[i].Post
+	}
+
+	if err := attachOriginals(ctx, s.db, user.ID, posts); err != nil {
+		return nil, Page{}, err
+	}
+
+	if err := attachMedia(ctx, s.db, posts); err != nil {
+		return nil, Page{}, err
+	}
+
+	return bookmarks, page, nil
+}
+
+func bookmarkCursor(b BookmarkedPost) Cursor {
+	return Cursor{CreatedAt: b.BookmarkedAt, ID: strconv.FormatInt(b.ID, 10)}
+}
@@ -5,7 +5,8 @@ import (
 )
 
 type Storage struct {
-	Users *UserStore
+	Users    *UserStore
+	Trending *TrendingStore
 }
 
 func NewCacheStore(rdb *redis.Client) *Storage {
@@ -13,5 +14,8 @@ func NewCacheStore(rdb *redis.Client) *Storage {
 		Users: &UserStore{
 			rdb: rdb,
 		},
+		Trending: &TrendingStore{
+			rdb: rdb,
+		},
 	}
 }
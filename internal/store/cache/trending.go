@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/vesselchuckk/go-social/internal/store"
+	"time"
+)
+
+type TrendingStore struct {
+	rdb *redis.Client
+}
+
+const TrendingExpTime = 5 * time.Minute
+
+func (s *TrendingStore) Get(ctx context.Context, window string) ([]store.TrendingTag, error) {
+	cacheKey := fmt.Sprintf("trending-tags-%s", window)
+
+	data, err := s.rdb.Get(ctx, cacheKey).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	tags := []store.TrendingTag{}
+	if err := json.Unmarshal([]byte(data), &tags); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (s *TrendingStore) Set(ctx context.Context, window string, tags []store.TrendingTag) error {
+	cacheKey := fmt.Sprintf("trending-tags-%s", window)
+
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+
+	return s.rdb.SetEX(ctx, cacheKey, data, TrendingExpTime).Err()
+}
@@ -16,6 +16,14 @@ type UserStore struct {
 
 const UserExpTime = time.Minute
 
+// cachedUser keeps the fields of a user that are hidden from API responses but
+// needed when authenticating or signing the avatar.
+type cachedUser struct {
+	*store.User
+	TokenVersion int    `json:"token_version"`
+	AvatarStatus string `json:"avatar_status"`
+}
+
 func (s *UserStore) Get(ctx context.Context, userID uuid.UUID) (*store.User, error) {
 	cacheKey := fmt.Sprintf("user-%v", userID)
 
@@ -28,10 +36,13 @@ func (s *UserStore) Get(ctx context.Context, userID uuid.UUID) (*store.User, err
 
 	var user store.User
 	if data != "" {
-		err := json.Unmarshal([]byte(data), &user)
+		cached := cachedUser{User: &user}
+		err := json.Unmarshal([]byte(data), &cached)
 		if err != nil {
 			return nil, err
 		}
+		user.TokenVersion = cached.TokenVersion
+		user.AvatarStatus = cached.AvatarStatus
 		return &user, nil
 	}
 
@@ -45,7 +56,7 @@ func (s *UserStore) Set(ctx context.Context, user *store.User) error {
 
 	cacheKey := fmt.Sprintf("user-%v", user.ID)
 
-	data, err := json.Marshal(user)
+	data, err := json.Marshal(cachedUser{User: user, TokenVersion: user.TokenVersion, AvatarStatus: user.AvatarStatus})
 	if err != nil {
 		return err
 	}
@@ -56,3 +67,9 @@ func (s *UserStore) Set(ctx context.Context, user *store.User) error {
 
 	return nil
 }
+
+func (s *UserStore) Delete(ctx context.Context, userID uuid.UUID) error {
+	cacheKey := fmt.Sprintf("user-%v", userID)
+
+	return s.rdb.Del(ctx, cacheKey).Err()
+}
@@ -3,18 +3,28 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"strconv"
 	"time"
 )
 
+// MaxCommentDepth caps how many levels of replies are loaded in a single thread query.
+const MaxCommentDepth = 5
+
 type Comment struct {
-	ID        int64     `json:"id" db:"id"`
-	PostID    int64     `json:"post_id" db:"post_id"`
-	UserID    uuid.UUID `json:"user_id" db:"user_id"`
-	Content   string    `json:"content" db:"content"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	User      User      `json:"user"`
+	ID         int64     `json:"id" db:"id"`
+	PostID     int64     `json:"post_id" db:"post_id"`
+	ParentID   *int64    `json:"parent_id" db:"parent_id"`
+	UserID     uuid.UUID `json:"user_id" db:"user_id"`
+	Content    string    `json:"content" db:"content"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	Version    int       `json:"version" db:"version"`
+	ReplyCount int       `json:"reply_count" db:"reply_count"`
+	User       User      `json:"user"`
+	Replies    []Comment `json:"replies,omitempty" db:"-"`
 }
 
 type CommentsStore struct {
@@ -28,12 +38,12 @@ func NewCommentsStore(db *sql.DB) *CommentsStore {
 }
 
 func (s *CommentsStore) Create(ctx context.Context, comment *Comment) error {
-	const query = `INSERT INTO comments (post_id, user_id, content) VALUES ($1, $2, $3) RETURNING id, created_at;`
+	const query = `INSERT INTO comments (post_id, parent_id, user_id, content) VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at, version;`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.GetContext(ctx, comment, query, comment.PostID, comment.UserID, comment.Content)
+	err := s.db.GetContext(ctx, comment, query, comment.PostID, comment.ParentID, comment.UserID, comment.Content)
 	if err != nil {
 		return err
 	}
@@ -41,32 +51,249 @@ func (s *CommentsStore) Create(ctx context.Context, comment *Comment) error {
 	return nil
 }
 
-func (s *CommentsStore) GetByPostID(ctx context.Context, postID int64) ([]Comment, error) {
-	const query = `SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, u.username, u.id
-               FROM comments c
-               JOIN users u ON u.id = c.user_id
-               WHERE c.post_id = $1
-               ORDER BY c.created_at DESC;`
+// ListByPostID returns a page of top-level comments on a post. Replies are
+// loaded separately through GetReplies.
+func (s *CommentsStore) ListByPostID(ctx context.Context, postID int64, fq PaginatedQuery) ([]Comment, Page, error) {
+	keyset, order, keysetArgs, err := fq.keyset("c.created_at", "c.id", bigintCursorID, 3)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	query := `
+SELECT
+    c.id, c.post_id, c.parent_id, c.user_id, c.content, c.created_at, c.updated_at, c.version,
+    u.username, u.id,
+    (SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id) AS reply_count
+FROM comments c
+JOIN users u ON u.id = c.user_id
+WHERE c.post_id = $1 AND c.parent_id IS NULL AND ` + keyset + `
+ORDER BY ` + order + `
+LIMIT $2;
+`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	args := append([]any{postID, fq.Limit + 1}, keysetArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, Page{}, err
 	}
 	defer rows.Close()
 
 	comments := []Comment{}
 	for rows.Next() {
 		var c Comment
-		c.User = User{}
-		err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.CreatedAt, &c.User.Username, &c.User.ID)
+		err := rows.Scan(
+			&c.ID,
+			&c.PostID,
+			&c.ParentID,
+			&c.UserID,
+			&c.Content,
+			&c.CreatedAt,
+			&c.UpdatedAt,
+			&c.Version,
+			&c.User.Username,
+			&c.User.ID,
+			&c.ReplyCount,
+		)
+		if err != nil {
+			return nil, Page{}, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Page{}, err
+	}
+
+	comments, page := paginate(comments, fq, commentCursor)
+
+	return comments, page, nil
+}
+
+func (s *CommentsStore) CountByPostID(ctx context.Context, postID int64) (int, error) {
+	const query = `SELECT COUNT(*) FROM comments WHERE post_id = $1;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+	if err := s.db.GetContext(ctx, &count, query, postID); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func commentCursor(c Comment) Cursor {
+	return Cursor{CreatedAt: c.CreatedAt, ID: strconv.FormatInt(c.ID, 10)}
+}
+
+// GetReplies returns the replies below a comment, nested up to depth levels.
+func (s *CommentsStore) GetReplies(ctx context.Context, comment *Comment, depth int) ([]Comment, error) {
+	return s.getThread(ctx, comment.PostID, &comment.ID, depth)
+}
+
+func (s *CommentsStore) getThread(ctx context.Context, postID int64, parentID *int64, depth int) ([]Comment, error) {
+	const query = `
+WITH RECURSIVE thread AS (
+    SELECT c.id, c.post_id, c.parent_id, c.user_id, c.content, c.created_at, c.updated_at, c.version, 1 AS depth
+    FROM comments c
+    WHERE c.post_id = $1 AND c.parent_id IS NOT DISTINCT FROM $2
+    UNION ALL
+    SELECT c.id, c.post_id, c.parent_id, c.user_id, c.content, c.created_at, c.updated_at, c.version, t.depth + 1
+    FROM comments c
+    JOIN thread t ON c.parent_id = t.id
+    WHERE t.depth < $3
+)
+SELECT
+    t.id, t.post_id, t.parent_id, t.user_id, t.content, t.created_at, t.updated_at, t.version,
+    u.username, u.id,
+    (SELECT COUNT(*) FROM comments r WHERE r.parent_id = t.id) AS reply_count
+FROM thread t
+JOIN users u ON u.id = t.user_id
+ORDER BY t.created_at DESC;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postID, parentID, depth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []Comment
+	for rows.Next() {
+		var c Comment
+		err := rows.Scan(
+			&c.ID,
+			&c.PostID,
+			&c.ParentID,
+			&c.UserID,
+			&c.Content,
+			&c.CreatedAt,
+			&c.UpdatedAt,
+			&c.Version,
+			&c.User.Username,
+			&c.User.ID,
+			&c.ReplyCount,
+		)
 		if err != nil {
 			return nil, err
 		}
 		comments = append(comments, c)
 	}
 
-	return comments, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return buildCommentTree(comments, parentID), nil
+}
+
+// buildCommentTree nests a flat list of comments under their parents. Comments
+// replying directly to rootID (or top-level comments when rootID is nil) form the roots.
+func buildCommentTree(comments []Comment, rootID *int64) []Comment {
+	roots := []Comment{}
+	children := make(map[int64][]Comment)
+
+	for _, c := range comments {
+		if c.ParentID == nil || (rootID != nil && *c.ParentID == *rootID) {
+			roots = append(roots, c)
+			continue
+		}
+		children[*c.ParentID] = append(children[*c.ParentID], c)
+	}
+
+	attachReplies(roots, children)
+
+	return roots
+}
+
+func attachReplies(comments []Comment, children map[int64][]Comment) {
+	for i := range comments {
+		comments[i].Replies = children[comments[i].ID]
+		attachReplies(comments[i].Replies, children)
+	}
+}
+
+func (s *CommentsStore) GetByID(ctx context.Context, id int64) (*Comment, error) {
+	const query = `SELECT c.id, c.post_id, c.parent_id, c.user_id, c.content, c.created_at, c.updated_at, c.version, u.username, u.id,
+			   (SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id) AS reply_count
+			   FROM comments c
+			   JOIN users u ON u.id = c.user_id
+			   WHERE c.id = $1;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var c Comment
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&c.ID,
+		&c.PostID,
+		&c.ParentID,
+		&c.UserID,
+		&c.Content,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+		&c.Version,
+		&c.User.Username,
+		&c.User.ID,
+		&c.ReplyCount,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (s *CommentsStore) Update(ctx context.Context, comment *Comment) error {
+	const query = `UPDATE comments
+				   SET content = $1, version = version + 1, updated_at = NOW()
+				   WHERE id = $2 AND version = $3
+				   RETURNING version, updated_at;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, comment.Content, comment.ID, comment.Version).Scan(&comment.Version, &comment.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *CommentsStore) Delete(ctx context.Context, comment *Comment) error {
+	const query = `DELETE FROM comments WHERE id = $1 AND version = $2;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, comment.ID, comment.Version)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
+	return nil
 }
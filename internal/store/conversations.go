@@ -0,0 +1,320 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"strconv"
+	"time"
+)
+
+// MaxConversationMembers caps the size of group conversations, creator included.
+const MaxConversationMembers = 10
+
+var (
+	ErrInvalidMembers       = errors.New("conversation members must be existing active users")
+	ErrDirectAlreadyStarted = errors.New("a conversation between these users already exists")
+)
+
+type Conversation struct {
+	ID          int64                `json:"id" db:"id"`
+	CreatedBy   uuid.UUID            `json:"created_by" db:"created_by"`
+	IsGroup     bool                 `json:"is_group" db:"is_group"`
+	Title       *string              `json:"title" db:"title"`
+	CreatedAt   time.Time            `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at" db:"updated_at"`
+	UnreadCount int                  `json:"unread_count" db:"unread_count"`
+	Members     []ConversationMember `json:"members" db:"-"`
+}
+
+type ConversationMember struct {
+	ConversationID    int64     `json:"-" db:"conversation_id"`
+	UserID            uuid.UUID `json:"user_id" db:"user_id"`
+	Username          string    `json:"username" db:"username"`
+	LastReadMessageID *int64    `json:"last_read_message_id" db:"last_read_message_id"`
+	JoinedAt          time.Time `json:"joined_at" db:"joined_at"`
+}
+
+type Message struct {
+	ID             int64     `json:"id" db:"id"`
+	ConversationID int64     `json:"conversation_id" db:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id" db:"sender_id"`
+	Content        string    `json:"content" db:"content"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+type ConversationsStore struct {
+	db *sqlx.DB
+}
+
+func NewConversationsStore(db *sql.DB) *ConversationsStore {
+	return &ConversationsStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// Create starts a conversation between its creator and the given members. A
+// one-to-one conversation is unique per pair of users: starting a second one
+// fails with ErrDirectAlreadyStarted.
+func (s *ConversationsStore) Create(ctx context.Context, conv *Conversation, memberIDs []uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sqlx.Tx) error {
+		const insertConversation = `
+INSERT INTO conversations (created_by, is_group, title, direct_key)
+VALUES ($1, $2, $3, $4)
+RETURNING id, created_at, updated_at;
+`
+
+		var key *string
+		if !conv.IsGroup && len(memberIDs) == 1 {
+			k := directKey(conv.CreatedBy, memberIDs[0])
+			key = &k
+		}
+
+		err := tx.QueryRowContext(ctx, insertConversation, conv.CreatedBy, conv.IsGroup, conv.Title, key).Scan(&conv.ID, &conv.CreatedAt, &conv.UpdatedAt)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrDirectAlreadyStarted
+			}
+			return err
+		}
+
+		const insertMembers = `
+INSERT INTO conversation_members (conversation_id, user_id)
+SELECT $1, u.id FROM users u WHERE u.id = ANY($2) AND u.is_active = true;
+`
+
+		ids := append([]uuid.UUID{conv.CreatedBy}, memberIDs...)
+
+		res, err := tx.ExecContext(ctx, insertMembers, conv.ID, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+
+		added, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if int(added) != len(ids) {
+			return ErrInvalidMembers
+		}
+
+		return nil
+	})
+}
+
+// FindDirect returns the one-to-one conversation between two users.
+func (s *ConversationsStore) FindDirect(ctx context.Context, userID, otherID uuid.UUID) (*Conversation, error) {
+	const query = `
+SELECT id, created_by, is_group, title, created_at, updated_at
+FROM conversations
+WHERE direct_key = $1;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var conv Conversation
+	if err := s.db.GetContext(ctx, &conv, query, directKey(userID, otherID)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &conv, s.loadMembers(ctx, &conv)
+}
+
+// directKey identifies the one-to-one conversation of two users. The pair is
+// ordered the way Postgres orders UUIDs, so either user yields the same key.
+func directKey(a, b uuid.UUID) string {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+
+	return a.String() + ":" + b.String()
+}
+
+// GetForMember returns a conversation the user takes part in. Conversations of
+// other users are reported as not found.
+func (s *ConversationsStore) GetForMember(ctx context.Context, id int64, userID uuid.UUID) (*Conversation, error) {
+	query := `
+SELECT c.id, c.created_by, c.is_group, c.title, c.created_at, c.updated_at, ` + unreadCountColumn + `
+FROM conversations c
+JOIN conversation_members m ON m.conversation_id = c.id AND m.user_id = $2
+WHERE c.id = $1;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var conv Conversation
+	if err := s.db.GetContext(ctx, &conv, query, id, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &conv, s.loadMembers(ctx, &conv)
+}
+
+// unreadCountColumn counts the messages of conversation c that member m has not
+// read yet, leaving out the ones m sent.
+const unreadCountColumn = `
+    (SELECT COUNT(*) FROM messages msg
+     WHERE msg.conversation_id = c.id AND msg.sender_id <> m.user_id AND msg.id > COALESCE(m.last_read_message_id, 0)
+    ) AS unread_count`
+
+// List returns the conversations of the user, most recently active first unless
+// fq asks for ascending order.
+func (s *ConversationsStore) List(ctx context.Context, user *User, fq PaginatedQuery) ([]Conversation, Page, error) {
+	keyset, order, keysetArgs, err := fq.keyset("c.updated_at", "c.id", bigintCursorID, 3)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	query := `
+SELECT c.id, c.created_by, c.is_group, c.title, c.created_at, c.updated_at, ` + unreadCountColumn + `
+FROM conversations c
+JOIN conversation_members m ON m.conversation_id = c.id AND m.user_id = $1
+WHERE ` + keyset + `
+ORDER BY ` + order + `
+LIMIT $2;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := append([]any{user.ID, fq.Limit + 1}, keysetArgs...)
+
+	convs := []Conversation{}
+	if err := s.db.SelectContext(ctx, &convs, query, args...); err != nil {
+		return nil, Page{}, err
+	}
+
+	convs, page := paginate(convs, fq, conversationCursor)
+
+	for i := range convs {
+		if err := s.loadMembers(ctx, &convs[i]); err != nil {
+			return nil, Page{}, err
+		}
+	}
+
+	return convs, page, nil
+}
+
+func (s *ConversationsStore) loadMembers(ctx context.Context, conv *Conversation) error {
+	const query = `
+SELECT m.conversation_id, m.user_id, u.username, m.last_read_message_id, m.joined_at
+FROM conversation_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.conversation_id = $1
+ORDER BY m.joined_at, u.username;
+`
+
+	conv.Members = []ConversationMember{}
+	return s.db.SelectContext(ctx, &conv.Members, query, conv.ID)
+}
+
+// CreateMessage stores a message and marks the conversation as active.
+func (s *ConversationsStore) CreateMessage(ctx context.Context, msg *Message) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sqlx.Tx) error {
+		const insertMessage = `
+INSERT INTO messages (conversation_id, sender_id, content)
+VALUES ($1, $2, $3)
+RETURNING id, created_at;
+`
+
+		if err := tx.QueryRowContext(ctx, insertMessage, msg.ConversationID, msg.SenderID, msg.Content).Scan(&msg.ID, &msg.CreatedAt); err != nil {
+			return err
+		}
+
+		const touchConversation = `UPDATE conversations SET updated_at = $2 WHERE id = $1;`
+
+		if _, err := tx.ExecContext(ctx, touchConversation, msg.ConversationID, msg.CreatedAt); err != nil {
+			return err
+		}
+
+		// Senders have read their own message.
+		const markRead = `
+UPDATE conversation_members SET last_read_message_id = $3
+WHERE conversation_id = $1 AND user_id = $2;
+`
+
+		_, err := tx.ExecContext(ctx, markRead, msg.ConversationID, msg.SenderID, msg.ID)
+		return err
+	})
+}
+
+// ListMessages returns the messages of a conversation, newest first unless fq
+// asks for ascending order.
+func (s *ConversationsStore) ListMessages(ctx context.Context, conversationID int64, fq PaginatedQuery) ([]Message, Page, error) {
+	keyset, order, keysetArgs, err := fq.keyset("msg.created_at", "msg.id", bigintCursorID, 3)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	query := `
+SELECT msg.id, msg.conversation_id, msg.sender_id, msg.content, msg.created_at
+FROM messages msg
+WHERE msg.conversation_id = $1 AND ` + keyset + `
+ORDER BY ` + order + `
+LIMIT $2;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := append([]any{conversationID, fq.Limit + 1}, keysetArgs...)
+
+	messages := []Message{}
+	if err := s.db.SelectContext(ctx, &messages, query, args...); err != nil {
+		return nil, Page{}, err
+	}
+
+	messages, page := paginate(messages, fq, messageCursor)
+
+	return messages, page, nil
+}
+
+// MarkRead moves the read marker of a member forward to messageID. Markers never
+// move backwards. It reports whether the marker moved.
+func (s *ConversationsStore) MarkRead(ctx context.Context, conversationID int64, userID uuid.UUID, messageID int64) (bool, error) {
+	const query = `
+UPDATE conversation_members m
+SET last_read_message_id = $3
+WHERE m.conversation_id = $1 AND m.user_id = $2
+  AND (m.last_read_message_id IS NULL OR m.last_read_message_id < $3)
+  AND EXISTS (SELECT 1 FROM messages msg WHERE msg.id = $3 AND msg.conversation_id = $1);
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, conversationID, userID, messageID)
+	if err != nil {
+		return false, err
+	}
+
+	moved, err := res.RowsAffected()
+	return moved > 0, err
+}
+
+func conversationCursor(c Conversation) Cursor {
+	return Cursor{CreatedAt: c.UpdatedAt, ID: strconv.FormatInt(c.ID, 10)}
+}
+
+func messageCursor(m Message) Cursor {
+	return Cursor{CreatedAt: m.CreatedAt, ID: strconv.FormatInt(m.ID, 10)}
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+var ErrAlreadyRequested = errors.New("a follow request is already pending")
+
+type FollowRequest struct {
+	RequesterID uuid.UUID `json:"requester_id" db:"requester_id"`
+	TargetID    uuid.UUID `json:"target_id" db:"target_id"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	Requester   User      `json:"requester" db:"requester"`
+}
+
+type FollowRequestsStore struct {
+	db *sqlx.DB
+}
+
+func NewFollowRequestsStore(db *sql.DB) *FollowRequestsStore {
+	return &FollowRequestsStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+func (s *FollowRequestsStore) Create(ctx context.Context, requesterID, targetID uuid.UUID) error {
+	const query = `INSERT INTO follow_requests (requester_id, target_id) VALUES ($1, $2);`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, requesterID, targetID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrAlreadyRequested
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Delete withdraws or rejects a pending request.
+func (s *FollowRequestsStore) Delete(ctx context.Context, requesterID, targetID uuid.UUID) error {
+	const query = `DELETE FROM follow_requests WHERE requester_id = $1 AND target_id = $2;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, requesterID, targetID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Approve turns a pending request into a follow.
+func (s *FollowRequestsStore) Approve(ctx context.Context, requesterID, targetID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sqlx.Tx) error {
+		const query = `DELETE FROM follow_requests WHERE requester_id = $1 AND target_id = $2;`
+
+		res, err := tx.ExecContext(ctx, query, requesterID, targetID)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if deleted == 0 {
+			return ErrNotFound
+		}
+
+		const follow = `INSERT INTO followers (user_id, follower_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;`
+
+		_, err = tx.ExecContext(ctx, follow, targetID, requesterID)
+		return err
+	})
+}
+
+// ListPending returns the requests waiting for the user's approval, most
+// recent first unless fq asks for ascending order.
+func (s *FollowRequestsStore) ListPending(ctx context.Context, user *User, fq PaginatedQuery) ([]FollowRequest, Page, error) {
+	keyset, order, keysetArgs, err := fq.keyset("fr.created_at", "fr.requester_id", uuidCursorID, 3)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	query := `
+SELECT fr.requester_id, fr.target_id, fr.created_at,
+    u.id AS "requester.id",
+    u.username AS "requester.username"
+FROM follow_requests fr
+JOIN users u ON u.id = fr.requester_id
+WHERE
+    fr.target_id = $1 AND
+    u.is_active = true AND
+    ` + keyset + `
+ORDER BY ` + order + `
+LIMIT $2;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := append([]any{user.ID, fq.Limit + 1}, keysetArgs...)
+
+	requests := []FollowRequest{}
+	if err := s.db.SelectContext(ctx, &requests, query, args...); err != nil {
+		return nil, Page{}, err
+	}
+
+	requests, page := paginate(requests, fq, followRequestCursor)
+
+	return requests, page, nil
+}
+
+func followRequestCursor(fr FollowRequest) Cursor {
+	return Cursor{CreatedAt: fr.CreatedAt, ID: fr.RequesterID.String()}
+}
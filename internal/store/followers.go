@@ -3,13 +3,15 @@ package store
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"errors"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 	"time"
 )
 
+var ErrAlreadyFollowing = errors.New("already following this user")
+
 type FollowerStore struct {
 	db *sqlx.DB
 }
@@ -35,12 +37,24 @@ func (s *FollowerStore) Follow(ctx context.Context, followerID, userID uuid.UUID
 	_, err := s.db.ExecContext(ctx, query, userID, followerID)
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-			return fmt.Errorf("duplicate key violates unique constraint")
+			return ErrAlreadyFollowing
 		}
+		return err
 	}
 	return nil
 }
 
+func (s *FollowerStore) IsFollowing(ctx context.Context, followerID, userID uuid.UUID) (bool, error) {
+	const query = `SELECT EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2);`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var following bool
+	err := s.db.GetContext(ctx, &following, query, userID, followerID)
+	return following, err
+}
+
 func (s *FollowerStore) Unfollow(ctx context.Context, followerID, userID uuid.UUID) error {
 	const query = `DELETE FROM followers WHERE user_id=$1 AND follower_id=$2;`
 
@@ -50,3 +64,94 @@ func (s *FollowerStore) Unfollow(ctx context.Context, followerID, userID uuid.UU
 	_, err := s.db.ExecContext(ctx, query, userID, followerID)
 	return err
 }
+
+// FollowEntry is a user in a followers or following list. FollowsYou tells
+// whether that user follows the viewer of the list.
+type FollowEntry struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	Username   string    `json:"username" db:"username"`
+	FollowedAt time.Time `json:"followed_at" db:"followed_at"`
+	FollowsYou bool      `json:"follows_you" db:"follows_you"`
+}
+
+// FollowStats describes a user's follow graph, relative to a viewer.
+type FollowStats struct {
+	FollowersCount int  `json:"followers_count" db:"followers_count"`
+	FollowingCount int  `json:"following_count" db:"following_count"`
+	FollowsYou     bool `json:"follows_you" db:"follows_you"`
+	FollowedByYou  bool `json:"followed_by_you" db:"followed_by_you"`
+	RequestedByYou bool `json:"requested_by_you" db:"requested_by_you"`
+}
+
+// ListFollowers returns the users following userID, most recent first unless fq
+// asks for ascending order.
+func (s *FollowerStore) ListFollowers(ctx context.Context, userID, viewerID uuid.UUID, fq PaginatedQuery) ([]FollowEntry, Page, error) {
+	return s.list(ctx, "f.user_id", "f.follower_id", userID, viewerID, fq)
+}
+
+// ListFollowing returns the users userID follows, most recent first unless fq
+// asks for ascending order.
+func (s *FollowerStore) ListFollowing(ctx context.Context, userID, viewerID uuid.UUID, fq PaginatedQuery) ([]FollowEntry, Page, error) {
+	return s.list(ctx, "f.follower_id", "f.user_id", userID, viewerID, fq)
+}
+
+// list pages through the follow rows whose ownerCol is userID, returning the
+// users in entryCol.
+func (s *FollowerStore) list(ctx context.Context, ownerCol, entryCol string, userID, viewerID uuid.UUID, fq PaginatedQuery) ([]FollowEntry, Page, error) {
+	keyset, order, keysetArgs, err := fq.keyset("f.created_at", entryCol, uuidCursorID, 4)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	query := `
+SELECT u.id, u.username, f.created_at AS followed_at,
+    EXISTS (SELECT 1 FROM followers v WHERE v.user_id = $3 AND v.follower_id = u.id) AS follows_you
+FROM followers f
+JOIN users u ON u.id = ` + entryCol + `
+WHERE
+    ` + ownerCol + ` = $1 AND
+    u.is_active = true AND
+    ` + keyset + `
+ORDER BY ` + order + `
+LIMIT $2;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := append([]any{userID, fq.Limit + 1, viewerID}, keysetArgs...)
+
+	entries := []FollowEntry{}
+	if err := s.db.SelectContext(ctx, &entries, query, args...); err != nil {
+		return nil, Page{}, err
+	}
+
+	entries, page := paginate(entries, fq, followCursor)
+
+	return entries, page, nil
+}
+
+func (s *FollowerStore) Stats(ctx context.Context, userID, viewerID uuid.UUID) (*FollowStats, error) {
+	const query = `
+SELECT
+    (SELECT COUNT(*) FROM followers f JOIN users u ON u.id = f.follower_id WHERE f.user_id = $1 AND u.is_active = true) AS followers_count,
+    (SELECT COUNT(*) FROM followers f JOIN users u ON u.id = f.user_id WHERE f.follower_id = $1 AND u.is_active = true) AS following_count,
+    EXISTS (SELECT 1 FROM followers WHERE user_id = $2 AND follower_id = $1) AS follows_you,
+    EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2) AS followed_by_you,
+    EXISTS (SELECT 1 FROM follow_requests WHERE target_id = $1 AND requester_id = $2) AS requested_by_you;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var stats FollowStats
+	if err := s.db.GetContext(ctx, &stats, query, userID, viewerID); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+func followCursor(e FollowEntry) Cursor {
+	return Cursor{CreatedAt: e.FollowedAt, ID: e.ID.String()}
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxMentions caps how many distinct users a single post or comment can mention.
+const maxMentions = 20
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w{1,96})`)
+
+type Mention struct {
+	ID        int64     `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	PostID    int64     `json:"post_id" db:"post_id"`
+	CommentID *int64    `json:"comment_id" db:"comment_id"`
+	Content   string    `json:"content" db:"content"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	Author    User      `json:"author" db:"author"`
+}
+
+// ParseMentions returns the distinct usernames mentioned as @username in
+// content, in the order they first appear.
+func ParseMentions(content string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		username := strings.ToLower(match[1])
+		if seen[username] {
+			continue
+		}
+		seen[username] = true
+
+		usernames = append(usernames, match[1])
+		if len(usernames) == maxMentions {
+			break
+		}
+	}
+
+	return usernames
+}
+
+type MentionsStore struct {
+	db *sqlx.DB
+}
+
+func NewMentionsStore(db *sql.DB) *MentionsStore {
+	return &MentionsStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// Record stores a mention for every active user named in content and returns
+// the IDs of the users that were mentioned. Authors never mention themselves,
+// nor users they blocked or were blocked by.
+func (s *MentionsStore) Record(ctx context.Context, authorID uuid.UUID, postID int64, commentID *int64, content string) ([]uuid.UUID, error) {
+	usernames := ParseMentions(content)
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	const query = `
+INSERT INTO mentions (user_id, author_id, post_id, comment_id)
+SELECT u.id, $1, $2, $3
+FROM users u
+WHERE LOWER(u.username) = ANY(SELECT LOWER(name) FROM unnest($4::text[]) AS name)
+  AND u.is_active = true
+  AND u.id <> $1
+  AND NOT EXISTS (
+      SELECT 1 FROM blocks b
+      WHERE (b.blocker_id = u.id AND b.blocked_id = $1) OR (b.blocker_id = $1 AND b.blocked_id = u.id)
+  )
+RETURNING user_id;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var mentioned []uuid.UUID
+	if err := s.db.SelectContext(ctx, &mentioned, query, authorID, postID, commentID, pq.Array(usernames)); err != nil {
+		return nil, err
+	}
+
+	return mentioned, nil
+}
+
+// List returns the mentions of the user in live posts and their comments,
+// newest first unless fq asks for ascending order.
+func (s *MentionsStore) List(ctx context.Context, user *User, fq PaginatedQuery) ([]Mention, Page, error) {
+	keyset, order, keysetArgs, err := fq.keyset("m.created_at", "m.id", bigintCursorID, 3)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	query := `
+SELECT m.id, m.user_id, m.post_id, m.comment_id, m.created_at,
+    COALESCE(c.content, p.content) AS content,
+    a.id AS "author.id",
+    a.username AS "author.username"
+FROM mentions m
+JOIN posts p ON p.id = m.post_id
+JOIN users a ON a.id = m.author_id
+LEFT JOIN comments c ON c.id = m.comment_id
+WHERE
+    m.user_id = $1 AND
+    ` + livePostClause + ` AND
+    ` + visibleAuthorClause + ` AND
+    ` + keyset + `
+ORDER BY ` + order + `
+LIMIT $2;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := append([]any{user.ID, fq.Limit + 1}, keysetArgs...)
+
+	mentions := []Mention{}
+	if err := s.db.SelectContext(ctx, &mentions, query, args...); err != nil {
+		return nil, Page{}, err
+	}
+
+	mentions, page := paginate(mentions, fq, mentionCursor)
+
+	return mentions, page, nil
+}
+
+func mentionCursor(m Mention) Cursor {
+	return Cursor{CreatedAt: m.CreatedAt, ID: strconv.FormatInt(m.ID, 10)}
+}
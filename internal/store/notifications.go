@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"strconv"
+	"time"
+)
+
+const (
+	NotificationFollow         = "follow"
+	NotificationFollowRequest  = "follow_request"
+	NotificationFollowAccepted = "follow_accepted"
+	NotificationComment        = "comment"
+	NotificationReply          = "reply"
+	NotificationMention        = "mention"
+	NotificationReaction       = "reaction"
+)
+
+type Notification struct {
+	ID        int64      `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	ActorID   uuid.UUID  `json:"actor_id" db:"actor_id"`
+	Type      string     `json:"type" db:"type"`
+	PostID    *int64     `json:"post_id" db:"post_id"`
+	CommentID *int64     `json:"comment_id" db:"comment_id"`
+	Reaction  *string    `json:"reaction,omitempty" db:"reaction"`
+	ReadAt    *time.Time `json:"read_at" db:"read_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	Actor     User       `json:"actor" db:"actor"`
+	// Cursor marks the position of the notification, so clients can mark
+	// everything up to it as read.
+	Cursor string `json:"cursor" db:"-"`
+}
+
+type NotificationsStore struct {
+	db *sqlx.DB
+}
+
+func NewNotificationsStore(db *sql.DB) *NotificationsStore {
+	return &NotificationsStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+func (s *NotificationsStore) Create(ctx context.Context, n *Notification) error {
+	const query = `
+INSERT INTO notifications (user_id, actor_id, type, post_id, comment_id, reaction)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, created_at;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, n.UserID, n.ActorID, n.Type, n.PostID, n.CommentID, n.Reaction).Scan(&n.ID, &n.CreatedAt)
+}
+
+// List returns the notifications of the user, newest first unless fq asks for
+// ascending order.
+func (s *NotificationsStore) List(ctx context.Context, user *User, fq PaginatedQuery) ([]Notification, Page, error) {
+	keyset, order, keysetArgs, err := fq.keyset("n.created_at", "n.id", bigintCursorID, 3)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	query := `
+SELECT n.id, n.user_id, n.actor_id, n.type, n.post_id, n.comment_id, n.reaction, n.read_at, n.created_at,
+    a.id AS "actor.id",
+    a.username AS "actor.username"
+FROM notifications n
+JOIN users a ON a.id = n.actor_id
+WHERE
+    n.user_id = $1 AND
+    ` + keyset + `
+ORDER BY ` + order + `
+LIMIT $2;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := append([]any{user.ID, fq.Limit + 1}, keysetArgs...)
+
+	notifications := []Notification{}
+	if err := s.db.SelectContext(ctx, &notifications, query, args...); err != nil {
+		return nil, Page{}, err
+	}
+
+	notifications, page := paginate(notifications, fq, notificationCursor)
+
+	for i := range notifications {
+		notifications[i].Cursor = notificationCursor(notifications[i]).Encode()
+	}
+
+	return notifications, page, nil
+}
+
+func (s *NotificationsStore) CountUnread(ctx context.Context, userID uuid.UUID) (int, error) {
+	const query = `SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var count int
+	err := s.db.GetContext(ctx, &count, query, userID)
+	return count, err
+}
+
+// MarkRead marks the unread notifications of the user as read, up to and
+// including the one at cursor. A nil cursor marks all of them.
+func (s *NotificationsStore) MarkRead(ctx context.Context, userID uuid.UUID, cursor *Cursor) (int64, error) {
+	query := `UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL`
+	args := []any{userID}
+
+	if cursor != nil {
+		query += ` AND (created_at, id) <= ($2, $3)`
+		args = append(args, cursor.CreatedAt, cursor.ID)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func notificationCursor(n Notification) Cursor {
+	return Cursor{CreatedAt: n.CreatedAt, ID: strconv.FormatInt(n.ID, 10)}
+}
@@ -1,20 +1,61 @@
 package store
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 )
 
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
 type PaginatedQuery struct {
 	Limit  int      `json:"limit" validate:"gte=1,lte=20"`
-	Offset int      `json:"offset" validate:"gte=0"`
 	Sort   string   `json:"sort" validate:"oneof=asc desc"`
 	Tags   []string `json:"tags" validate:"max=5"`
 	Search string   `json:"search" validate:"max=100"`
 	Since  string   `json:"since"`
 	Until  string   `json:"until"`
+	Cursor *Cursor  `json:"-"`
+}
+
+// Cursor marks the position of a row in a listing ordered by (created_at, id).
+// A backward cursor walks towards the start of the listing.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+	Backward  bool      `json:"b,omitempty"`
+}
+
+// Page carries the opaque cursors a client passes back to continue a listing.
+type Page struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
 }
 
 func (fq PaginatedQuery) Parse(r *http.Request) (PaginatedQuery, error) {
@@ -30,16 +71,6 @@ func (fq PaginatedQuery) Parse(r *http.Request) (PaginatedQuery, error) {
 		fq.Limit = l
 	}
 
-	offset := qs.Get("offset")
-	if offset != "" {
-		l, err := strconv.Atoi(offset)
-		if err != nil {
-			return fq, nil
-		}
-
-		fq.Offset = l
-	}
-
 	sort := qs.Get("sort")
 	if sort != "" {
 		fq.Sort = sort
@@ -47,7 +78,7 @@ func (fq PaginatedQuery) Parse(r *http.Request) (PaginatedQuery, error) {
 
 	tags := qs.Get("tags")
 	if tags != "" {
-		fq.Tags = strings.Split(tags, " ")
+		fq.Tags = strings.Split(strings.ToLower(tags), " ")
 	}
 
 	search := qs.Get("search")
@@ -60,14 +91,107 @@ func (fq PaginatedQuery) Parse(r *http.Request) (PaginatedQuery, error) {
 		fq.Since = parseTime(since)
 	}
 
-	until := qs.Get("since")
+	until := qs.Get("until")
 	if until != "" {
 		fq.Until = parseTime(until)
 	}
 
+	cursor := qs.Get("cursor")
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return fq, err
+		}
+
+		fq.Cursor = c
+	}
+
 	return fq, nil
 }
 
+// cursorID parses the ID of a cursor into the type of a keyset column, so a
+// tampered cursor is rejected before it reaches the query.
+type cursorID func(string) (any, error)
+
+func bigintCursorID(id string) (any, error) {
+	return strconv.ParseInt(id, 10, 64)
+}
+
+func uuidCursorID(id string) (any, error) {
+	return uuid.Parse(id)
+}
+
+// keyset builds the condition and ordering that continue a listing from fq.Cursor.
+// timeCol and idCol name the keyset columns, and parseID checks the cursor ID
+// against the type of idCol. The cursor values bind to placeholders $n and $n+1
+// and are returned as args, so they must come last in the query arguments.
+// Backward cursors scan in the opposite direction and paginate restores the order.
+func (fq PaginatedQuery) keyset(timeCol, idCol string, parseID cursorID, n int) (string, string, []any, error) {
+	desc := fq.Sort != "asc"
+	if fq.backward() {
+		desc = !desc
+	}
+
+	dir, cmp := "ASC", ">"
+	if desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	order := fmt.Sprintf("%s %s, %s %s", timeCol, dir, idCol, dir)
+
+	if fq.Cursor == nil {
+		return "TRUE", order, nil, nil
+	}
+
+	id, err := parseID(fq.Cursor.ID)
+	if err != nil {
+		return "", "", nil, ErrInvalidCursor
+	}
+
+	cond := fmt.Sprintf("(%s, %s) %s ($%d, $%d)", timeCol, idCol, cmp, n, n+1)
+
+	return cond, order, []any{fq.Cursor.CreatedAt, id}, nil
+}
+
+// paginate drops the extra row queried beyond fq.Limit to detect another page,
+// puts rows fetched by a backward cursor back into listing order and builds the
+// cursors around the rows kept.
+func paginate[T any](items []T, fq PaginatedQuery, cursorOf func(T) Cursor) ([]T, Page) {
+	var page Page
+
+	hasMore := len(items) > fq.Limit
+	if hasMore {
+		items = items[:fq.Limit]
+	}
+
+	if len(items) == 0 {
+		return items, page
+	}
+
+	backward := fq.backward()
+	if backward {
+		slices.Reverse(items)
+	}
+
+	first := cursorOf(items[0])
+	first.Backward = true
+	last := cursorOf(items[len(items)-1])
+
+	if hasMore || backward {
+		page.NextCursor = last.Encode()
+	}
+
+	if (hasMore && backward) || (fq.Cursor != nil && !backward) {
+		page.PrevCursor = first.Encode()
+	}
+
+	return items, page
+}
+
+func (fq PaginatedQuery) backward() bool {
+	return fq.Cursor != nil && fq.Cursor.Backward
+}
+
 func parseTime(s string) string {
 	t, err := time.Parse(time.DateTime, s)
 	if err != nil {
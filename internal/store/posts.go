@@ -3,32 +3,72 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
+	"strconv"
 	"time"
 )
 
+var ErrAlreadyReposted = errors.New("post has already been reposted")
+
+const (
+	PostKindPost   = "post"
+	PostKindRepost = "repost"
+	PostKindQuote  = "quote"
+)
+
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+)
+
 type Post struct {
 	ID      int64          `json:"id"  db:"id"`
 	Title   string         `json:"title" db:"title"`
 	Content string         `json:"content" db:"content"`
 	Tags    pq.StringArray `json:"tags" db:"tags"`
 
+	// Kind tells plain posts from reposts and quotes, which reference the
+	// original post through RepostOf.
+	Kind                string `json:"kind" db:"kind"`
+	RepostOf            *int64 `json:"repost_of" db:"repost_of"`
+	Original            *Post  `json:"original,omitempty" db:"-"`
+	OriginalUnavailable bool   `json:"original_unavailable,omitempty" db:"-"`
+	RepostCount         int    `json:"repost_count" db:"repost_count"`
+
+	// Status keeps drafts and scheduled posts out of listings until they are
+	// published, at PublishAt for scheduled ones.
+	Status    string     `json:"status" db:"status"`
+	PublishAt *time.Time `json:"publish_at,omitempty" db:"publish_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+
 	UserID    uuid.UUID `json:"user_id" db:"user_id"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 
 	Version int `json:"version" db:"version"`
 
-	Comments []Comment `json:"comments" db:"comments"`
-	User     User      `json:"user"`
+	// Attachments holds the uploaded media of the post. When creating a post
+	// only their IDs need to be set.
+	Attachments []Attachment `json:"attachments" db:"-"`
+	// MediaStatus is "processing" until every attachment is ready to serve.
+	MediaStatus string `json:"media_status,omitempty" db:"-"`
+
+	Comments    []Comment      `json:"comments" db:"comments"`
+	User        User           `json:"user"`
+	Reactions   ReactionCounts `json:"reactions" db:"-"`
+	MyReactions pq.StringArray `json:"my_reactions" db:"-"`
+	Reacted     bool           `json:"reacted" db:"-"`
 }
 
 type PostMetadata struct {
 	Post
-	CommentCount int `json:"comment_count" db:"comment_count"`
+	CommentCount  int `json:"comment_count" db:"comment_count"`
+	ReactionCount int `json:"reaction_count" db:"reaction_count"`
 }
 
 type PostsStore struct {
@@ -41,126 +81,689 @@ func NewPostsStore(db *sql.DB) *PostsStore {
 	}
 }
 
-func (s *PostsStore) GetUserFeed(ctx context.Context, user *User, fq PaginatedQuery) ([]PostMetadata, error) {
-	query := `
-SELECT 
-    p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags,
+// postMetadataColumns selects what scanPostMetadata reads for the post aliased p
+// and its author aliased u. Listing queries bind the viewer to $1.
+const postMetadataColumns = `
+    p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags, p.kind, p.repost_of,
+    p.status, p.publish_at,
     u.username,
-    COUNT(c.id) AS comments_count
+    ` + repostCountColumn + `,
+    (SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments_count,` + reactionColumns
+
+const repostCountColumn = `(SELECT COUNT(*) FROM posts rp WHERE rp.repost_of = p.id AND rp.deleted_at IS NULL) AS repost_count`
+
+// livePostClause keeps the post aliased p when it is published and not in the
+// trash, and for plain reposts when the reposted post is live as well.
+const livePostClause = `
+    p.deleted_at IS NULL AND p.status = 'published' AND
+    (p.kind <> 'repost' OR EXISTS (
+        SELECT 1 FROM posts o WHERE o.id = p.repost_of AND o.deleted_at IS NULL AND o.status = 'published'
+    ))`
+
+// visibleAuthorClause hides the posts of private accounts from viewers ($1)
+// that do not follow them, and the posts of users the viewer blocked or was
+// blocked by.
+const visibleAuthorClause = `
+    (p.user_id = $1 OR
+     NOT EXISTS (SELECT 1 FROM users pa WHERE pa.id = p.user_id AND pa.is_private) OR
+     EXISTS (SELECT 1 FROM followers pf WHERE pf.user_id = p.user_id AND pf.follower_id = $1)) AND
+    NOT EXISTS (
+        SELECT 1 FROM blocks pb
+        WHERE (pb.blocker_id = $1 AND pb.blocked_id = p.user_id) OR (pb.blocker_id = p.user_id AND pb.blocked_id = $1)
+    )`
+
+// publicAuthorClause keeps only the posts of public accounts, for aggregates
+// that are shared by every viewer.
+const publicAuthorClause = `
+    NOT EXISTS (SELECT 1 FROM users pa WHERE pa.id = p.user_id AND pa.is_private)`
+
+// postFilters applies the search ($3) and tags ($4) filters of a PaginatedQuery
+// to the post aliased p.
+const postFilters = `
+    (p.title ILIKE '%' || $3 || '%' OR p.content ILIKE '%' || $3 || '%') AND
+    (p.tags @> $4 OR $4 IS NULL OR $4 = '{}')`
+
+func (s *PostsStore) GetUserFeed(ctx context.Context, user *User, fq PaginatedQuery) ([]PostMetadata, Page, error) {
+	keyset, order, keysetArgs, err := fq.keyset("p.created_at", "p.id", bigintCursorID, 5)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	query := `
+SELECT ` + postMetadataColumns + `
 FROM posts p
-LEFT JOIN comments c ON c.post_id = p.id
-LEFT JOIN users u ON p.user_id = u.id
-JOIN followers f ON f.follower_id = p.user_id OR p.user_id = $1
+JOIN users u ON p.user_id = u.id
 WHERE 
-    f.user_id = $1 AND
-    (p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') AND
-    (p.tags @> $5 OR $5 = '{}')
-GROUP BY p.id, u.username
-ORDER BY p.created_at ` + fq.Sort + `
-LIMIT $2 OFFSET $3;
+    (
+        p.user_id = $1 OR
+        p.user_id IN (SELECT f.user_id FROM followers f WHERE f.follower_id = $1) OR
+        p.tags && ARRAY(SELECT tf.tag FROM tag_follows tf WHERE tf.user_id = $1)
+    ) AND
+    NOT EXISTS (SELECT 1 FROM mutes mu WHERE mu.muter_id = $1 AND mu.muted_id = p.user_id) AND
+    ` + livePostClause + ` AND
+    ` + visibleAuthorClause + ` AND
+    ` + postFilters + ` AND
+    ` + keyset + `
+ORDER BY ` + order + `
+LIMIT $2;
 `
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, user.ID, fq.Limit, fq.Offset, fq.Search, pq.Array(fq.Tags))
+	args := append([]any{user.ID, fq.Limit + 1, fq.Search, pq.Array(fq.Tags)}, keysetArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, Page{}, err
 	}
 
 	defer rows.Close()
 
-	var feed []PostMetadata
+	feed := []PostMetadata{}
 	for rows.Next() {
 		var p PostMetadata
-		err := rows.Scan(
-			&p.ID,
-			&p.UserID,
-			&p.Title,
-			&p.Content,
-			&p.CreatedAt,
-			&p.Version,
-			pq.Array(&p.Tags),
-			&p.User.Username,
-			&p.CommentCount,
-		)
-		if err != nil {
-			return nil, err
+		if err := scanPostMetadata(rows, &p); err != nil {
+			return nil, Page{}, err
 		}
 
 		feed = append(feed, p)
 	}
 
-	return feed, nil
+	if err := rows.Err(); err != nil {
+		return nil, Page{}, err
+	}
+
+	feed, page := paginate(feed, fq, postCursor)
+
+	posts := make([]*Post, len(feed))
+	for i := range feed {
+		posts[i] = &feed[i].Post
+	}
+
+	if err := attachOriginals(ctx, s.db, user.ID, posts); err != nil {
+		return nil, Page{}, err
+	}
+
+	if err := attachMedia(ctx, s.db, posts); err != nil {
+		return nil, Page{}, err
+	}
+
+	return feed, page, nil
+}
+
+// scanPostMetadata scans a row selected with postMetadataColumns into p. Columns
+// selected after them are scanned into extra.
+func scanPostMetadata(rows *sql.Rows, p *PostMetadata, extra ...any) error {
+	dest := []any{
+		&p.ID,
+		&p.UserID,
+		&p.Title,
+		&p.Content,
+		&p.CreatedAt,
+		&p.Version,
+		pq.Array(&p.Tags),
+		&p.Kind,
+		&p.RepostOf,
+		&p.Status,
+		&p.PublishAt,
+		&p.User.Username,
+		&p.RepostCount,
+		&p.CommentCount,
+		&p.Reactions,
+		&p.MyReactions,
+	}
+
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	p.User.ID = p.UserID
+	p.Reacted = len(p.MyReactions) > 0
+	p.ReactionCount = p.Reactions.Total()
+
+	return nil
+}
+
+func postCursor(p PostMetadata) Cursor {
+	return Cursor{CreatedAt: p.CreatedAt, ID: strconv.FormatInt(p.ID, 10)}
 }
 
 func (s *PostsStore) CreatePost(ctx context.Context, post *Post) error {
 	const query = `
-	INSERT INTO posts (title, content, user_id)
-	VALUES ($1, $2, $3) RETURNING id, title, content, created_at;
+	INSERT INTO posts (title, content, user_id, kind, repost_of, status, publish_at, tags)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id, title, content, tags, kind, repost_of, status, publish_at, created_at, updated_at, version;
 	`
 
+	if post.Kind == "" {
+		post.Kind = PostKindPost
+	}
+	if post.Status == "" {
+		post.Status = PostStatusPublished
+	}
+
+	return withTx(s.db, ctx, func(tx *sqlx.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.GetContext(ctx, post, query, post.Title, post.Content, post.UserID, post.Kind, post.RepostOf, post.Status, post.PublishAt, post.Tags)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrAlreadyReposted
+			}
+			return fmt.Errorf("failed to create post: %w", err)
+		}
+
+		return linkAttachments(ctx, tx, post)
+	})
+}
+
+// DeleteRepost removes the plain repost of originalID made by userID.
+func (s *PostsStore) DeleteRepost(ctx context.Context, userID uuid.UUID, originalID int64) error {
+	const query = `DELETE FROM posts WHERE user_id = $1 AND repost_of = $2 AND kind = 'repost';`
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.GetContext(ctx, post, query, post.Title, post.Content, post.UserID)
+	result, err := s.db.ExecContext(ctx, query, userID, originalID)
 	if err != nil {
-		return fmt.Errorf("failed to create post: %w", err)
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// AttachOriginals embeds the original post into every repost and quote in posts,
+// as far as the viewer may see it.
+func (s *PostsStore) AttachOriginals(ctx context.Context, viewerID uuid.UUID, posts ...*Post) error {
+	return attachOriginals(ctx, s.db, viewerID, posts)
+}
+
+// AttachMedia loads the attachments of posts and of their embedded originals.
+func (s *PostsStore) AttachMedia(ctx context.Context, posts ...*Post) error {
+	return attachMedia(ctx, s.db, posts)
+}
+
+// attachOriginals loads the posts referenced by reposts and quotes in one query.
+// Posts whose original is gone are flagged with OriginalUnavailable.
+func attachOriginals(ctx context.Context, db *sqlx.DB, viewerID uuid.UUID, posts []*Post) error {
+	var ids []int64
+	for _, p := range posts {
+		if p.RepostOf != nil {
+			ids = append(ids, *p.RepostOf)
+		}
+	}
+
+	originals := make(map[int64]*Post)
+
+	if len(ids) > 0 {
+		const query = `SELECT p.id, p.user_id, p.title, p.content, p.tags, p.created_at, p.updated_at, p.version, p.kind, u.username
+					   FROM posts p
+					   JOIN users u ON u.id = p.user_id
+					   WHERE p.id = ANY($2) AND p.deleted_at IS NULL AND p.status = 'published' AND ` + visibleAuthorClause + `;`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		rows, err := db.QueryContext(ctx, query, viewerID, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var o Post
+			err := rows.Scan(
+				&o.ID,
+				&o.UserID,
+				&o.Title,
+				&o.Content,
+				pq.Array(&o.Tags),
+				&o.CreatedAt,
+				&o.UpdatedAt,
+				&o.Version,
+				&o.Kind,
+				&o.User.Username,
+			)
+			if err != nil {
+				return err
+			}
+
+			o.User.ID = o.UserID
+			originals[o.ID] = &o
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range posts {
+		if p.RepostOf != nil {
+			p.Original = originals[*p.RepostOf]
+		}
+		p.OriginalUnavailable = p.Kind != PostKindPost && p.Original == nil
 	}
 
 	return nil
 }
 
 func (s *PostsStore) GetByID(ctx context.Context, id int64) (*Post, error) {
-	const query = `SELECT * FROM posts WHERE id = $1;`
+	const query = `SELECT p.*, ` + repostCountColumn + `
+				   FROM posts p
+				   WHERE p.id = $1 AND p.deleted_at IS NULL;`
+
+	return s.getOne(ctx, query, id)
+}
+
+// GetVisible returns a post the viewer may read: published posts, and the
+// drafts and scheduled posts of the viewer. Posts of private accounts are only
+// visible to their followers.
+func (s *PostsStore) GetVisible(ctx context.Context, id int64, viewerID uuid.UUID) (*Post, error) {
+	const query = `SELECT p.*, ` + repostCountColumn + `
+				   FROM posts p
+				   WHERE p.id = $2 AND p.deleted_at IS NULL AND (p.status = 'published' OR p.user_id = $1) AND ` + visibleAuthorClause + `;`
+
+	return s.getOne(ctx, query, viewerID, id)
+}
+
+// GetTrashed returns a post that was moved to the trash and not purged yet.
+func (s *PostsStore) GetTrashed(ctx context.Context, id int64) (*Post, error) {
+	const query = `SELECT p.*, ` + repostCountColumn + `
+				   FROM posts p
+				   WHERE p.id = $1 AND p.deleted_at IS NOT NULL;`
 
+	return s.getOne(ctx, query, id)
+}
+
+func (s *PostsStore) getOne(ctx context.Context, query string, args ...any) (*Post, error) {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
 	var post Post
-	if err := s.db.GetContext(ctx, &post, query, id); err != nil {
+	if err := s.db.GetContext(ctx, &post, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
 		return nil, fmt.Errorf("failed to get post from DB: %w", err)
 	}
 
 	return &post, nil
 }
 
-func (s *PostsStore) Update(ctx context.Context, post *Post) error {
-	const query = `UPDATE posts 
-				   SET title= $1, content =$2, version=version+1
-				   WHERE id = $3 AND version = $4
-				   RETURNING version;`
+// Update saves the edited post when its version is still current and keeps the
+// replaced version in the post revisions. editorID is the user making the edit.
+func (s *PostsStore) Update(ctx context.Context, post *Post, editorID uuid.UUID) error {
+	return withTx(s.db, ctx, func(tx *sqlx.Tx) error {
+		if err := createRevision(ctx, tx, post.ID, post.Version, editorID); err != nil {
+			return err
+		}
+
+		// Publishing a draft or scheduled post moves it to the top of the feeds,
+		// which are ordered by created_at.
+		const query = `UPDATE posts 
+					   SET title = $1, content = $2, status = $5, publish_at = $6, tags = $7,
+					       created_at = CASE WHEN status <> 'published' AND $5 = 'published' THEN NOW() ELSE created_at END,
+					       version = version + 1, updated_at = NOW()
+					   WHERE id = $3 AND version = $4
+					   RETURNING version, created_at, updated_at;`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, post.Title, post.Content, post.ID, post.Version, post.Status, post.PublishAt, post.Tags).Scan(&post.Version, &post.CreatedAt, &post.UpdatedAt)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrEditConflict
+			}
+			return err
+		}
+
+		return nil
+	})
+}
+
+// Delete moves a post to the trash when its version is still current. Plain
+// reposts of it disappear from listings until the post is restored.
+func (s *PostsStore) Delete(ctx context.Context, id int64, version int) error {
+	const query = `UPDATE posts SET deleted_at = NOW() WHERE id = $1 AND version = $2 AND deleted_at IS NULL;`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.GetContext(ctx, &post, query, post.ID, post.Version)
+	result, err := s.db.ExecContext(ctx, query, id, version)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to delete a post from db: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrEditConflict
 	}
 
 	return nil
 }
 
-func (s *PostsStore) Delete(ctx context.Context, id int64) error {
-	const query = `DELETE FROM posts WHERE id = $1;`
+func (s *PostsStore) Restore(ctx context.Context, id int64) error {
+	const query = `UPDATE posts SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL;`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
 	result, err := s.db.ExecContext(ctx, query, id)
 	if err != nil {
-		return fmt.Errorf("failed to delete a post from db: %w", err)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrAlreadyReposted
+		}
+		return err
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to check rows affected: %w", err)
+		return err
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("no post found with id %d", id)
+		return ErrNotFound
 	}
 
 	return nil
 }
+
+// GetTrash lists the posts of the user that are in the trash, most recently
+// deleted first unless fq asks for ascending order.
+func (s *PostsStore) GetTrash(ctx context.Context, user *User, fq PaginatedQuery) ([]PostMetadata, Page, error) {
+	keyset, order, keysetArgs, err := fq.keyset("p.deleted_at", "p.id", bigintCursorID, 5)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	query := `
+SELECT ` + postMetadataColumns + `,
+    p.deleted_at
+FROM posts p
+JOIN users u ON p.user_id = u.id
+WHERE
+    p.user_id = $1 AND
+    p.deleted_at IS NOT NULL AND
+    ` + postFilters + ` AND
+    ` + keyset + `
+ORDER BY ` + order + `
+LIMIT $2;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := append([]any{user.ID, fq.Limit + 1, fq.Search, pq.Array(fq.Tags)}, keysetArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	defer rows.Close()
+
+	trash := []PostMetadata{}
+	for rows.Next() {
+		var p PostMetadata
+		if err := scanPostMetadata(rows, &p, &p.DeletedAt); err != nil {
+			return nil, Page{}, err
+		}
+
+		trash = append(trash, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Page{}, err
+	}
+
+	trash, page := paginate(trash, fq, trashCursor)
+
+	refs := make([]*Post, len(trash))
+	for i := range trash {
+		refs[i] = &trash[i].Post
+	}
+
+	if err := attachMedia(ctx, s.db, refs); err != nil {
+		return nil, Page{}, err
+	}
+
+	return trash, page, nil
+}
+
+func trashCursor(p PostMetadata) Cursor {
+	return Cursor{CreatedAt: *p.DeletedAt, ID: strconv.FormatInt(p.ID, 10)}
+}
+
+// GetByTag lists the live posts carrying the given tag.
+func (s *PostsStore) GetByTag(ctx context.Context, viewer *User, tag string, fq PaginatedQuery) ([]PostMetadata, Page, error) {
+	keyset, order, keysetArgs, err := fq.keyset("p.created_at", "p.id", bigintCursorID, 6)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	query := `
+SELECT ` + postMetadataColumns + `
+FROM posts p
+JOIN users u ON p.user_id = u.id
+WHERE
+    p.tags @> ARRAY[$5]::varchar(100)[] AND
+    ` + livePostClause + ` AND
+    ` + visibleAuthorClause + ` AND
+    ` + postFilters + ` AND
+    ` + keyset + `
+ORDER BY ` + order + `
+LIMIT $2;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := append([]any{viewer.ID, fq.Limit + 1, fq.Search, pq.Array(fq.Tags), tag}, keysetArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	defer rows.Close()
+
+	posts := []PostMetadata{}
+	for rows.Next() {
+		var p PostMetadata
+		if err := scanPostMetadata(rows, &p); err != nil {
+			return nil, Page{}, err
+		}
+
+		posts = append(posts, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Page{}, err
+	}
+
+	posts, page := paginate(posts, fq, postCursor)
+
+	refs := make([]*Post, len(posts))
+	for i := range posts {
+		refs[i] = &posts[i].Post
+	}
+
+	if err := attachOriginals(ctx, s.db, viewer.ID, refs); err != nil {
+		return nil, Page{}, err
+	}
+
+	if err := attachMedia(ctx, s.db, refs); err != nil {
+		return nil, Page{}, err
+	}
+
+	return posts, page, nil
+}
+
+// GetDrafts lists the drafts and scheduled posts of the user.
+func (s *PostsStore) GetDrafts(ctx context.Context, user *User, fq PaginatedQuery) ([]PostMetadata, Page, error) {
+	keyset, order, keysetArgs, err := fq.keyset("p.created_at", "p.id", bigintCursorID, 5)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	query := `
+SELECT ` + postMetadataColumns + `
+FROM posts p
+JOIN users u ON p.user_id = u.id
+WHERE
+    p.user_id = $1 AND
+    p.status IN ('draft', 'scheduled') AND
+    p.deleted_at IS NULL AND
+    ` + postFilters + ` AND
+    ` + keyset + `
+ORDER BY ` + order + `
+LIMIT $2;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	args := append([]any{user.ID, fq.Limit + 1, fq.Search, pq.Array(fq.Tags)}, keysetArgs...)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	defer rows.Close()
+
+	drafts := []PostMetadata{}
+	for rows.Next() {
+		var p PostMetadata
+		if err := scanPostMetadata(rows, &p); err != nil {
+			return nil, Page{}, err
+		}
+
+		drafts = append(drafts, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, Page{}, err
+	}
+
+	drafts, page := paginate(drafts, fq, postCursor)
+
+	refs := make([]*Post, len(drafts))
+	for i := range drafts {
+		refs[i] = &drafts[i].Post
+	}
+
+	if err := attachMedia(ctx, s.db, refs); err != nil {
+		return nil, Page{}, err
+	}
+
+	return drafts, page, nil
+}
+
+// PublishDue publishes up to limit scheduled posts whose publish time has come.
+// Rows claimed by another instance are skipped, so every post is published once
+// even when several schedulers run at the same time.
+func (s *PostsStore) PublishDue(ctx context.Context, limit int) ([]Post, error) {
+	const query = `
+WITH due AS (
+    SELECT id FROM posts
+    WHERE status = 'scheduled' AND publish_at <= NOW() AND deleted_at IS NULL
+    ORDER BY publish_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE posts p
+SET status = 'published', created_at = NOW(), updated_at = NOW(), version = version + 1
+FROM due
+WHERE p.id = due.id
+RETURNING p.id, p.user_id, p.title, p.content, p.tags, p.kind, p.repost_of, p.status, p.publish_at, p.created_at, p.updated_at, p.version;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	posts := []Post{}
+	if err := s.db.SelectContext(ctx, &posts, query, limit); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// PurgeDeleted permanently removes the posts that were moved to the trash before
+// the given time, along with their comments and plain reposts.
+func (s *PostsStore) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+
+	err := withTx(s.db, ctx, func(tx *sqlx.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		const repostsQuery = `DELETE FROM posts
+							  WHERE kind = 'repost' AND repost_of IN (SELECT id FROM posts WHERE deleted_at < $1);`
+		if _, err := tx.ExecContext(ctx, repostsQuery, before); err != nil {
+			return err
+		}
+
+		const commentsQuery = `DELETE FROM comments
+							   WHERE post_id IN (SELECT id FROM posts WHERE deleted_at < $1);`
+		if _, err := tx.ExecContext(ctx, commentsQuery, before); err != nil {
+			return err
+		}
+
+		const query = `DELETE FROM posts WHERE deleted_at < $1;`
+		result, err := tx.ExecContext(ctx, query, before)
+		if err != nil {
+			return err
+		}
+
+		purged, err = result.RowsAffected()
+		return err
+	})
+
+	return purged, err
+}
+
+// FeedAudience returns the users whose feed a published post lands in: the
+// followers of its author and, for public accounts, the followers of any of its
+// tags, minus the users who muted the author or are blocked either way.
+func (s *PostsStore) FeedAudience(ctx context.Context, post *Post) ([]uuid.UUID, error) {
+	const query = `
+SELECT audience.id FROM (
+    SELECT f.follower_id AS id FROM followers f WHERE f.user_id = $1
+    UNION
+    SELECT tf.user_id AS id FROM tag_follows tf
+    WHERE tf.tag = ANY($2) AND tf.user_id <> $1
+      AND NOT EXISTS (SELECT 1 FROM users a WHERE a.id = $1 AND a.is_private)
+) audience
+WHERE
+    NOT EXISTS (SELECT 1 FROM mutes mu WHERE mu.muter_id = audience.id AND mu.muted_id = $1) AND
+    NOT EXISTS (
+        SELECT 1 FROM blocks b
+        WHERE (b.blocker_id = $1 AND b.blocked_id = audience.id) OR (b.blocker_id = audience.id AND b.blocked_id = $1)
+    );
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	audience := []uuid.UUID{}
+	if err := s.db.SelectContext(ctx, &audience, query, post.UserID, pq.Array(post.Tags)); err != nil {
+		return nil, err
+	}
+
+	return audience, nil
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// reactionColumns aggregates the reactions of the post aliased p, including the
+// ones left by the viewer bound to $1.
+const reactionColumns = `
+    COALESCE((
+        SELECT jsonb_object_agg(rc.type, rc.count)
+        FROM (SELECT type, COUNT(*) AS count FROM post_reactions WHERE post_id = p.id GROUP BY type) rc
+    ), '{}') AS reactions,
+    ARRAY(SELECT type FROM post_reactions WHERE post_id = p.id AND user_id = $1) AS my_reactions`
+
+// ReactionCounts maps a reaction type to the number of users who reacted with it.
+type ReactionCounts map[string]int
+
+func (rc *ReactionCounts) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*rc = ReactionCounts{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, rc)
+	case string:
+		return json.Unmarshal([]byte(v), rc)
+	default:
+		return fmt.Errorf("cannot scan %T into ReactionCounts", src)
+	}
+}
+
+func (rc ReactionCounts) Total() int {
+	total := 0
+	for _, count := range rc {
+		total += count
+	}
+	return total
+}
+
+type ReactionsStore struct {
+	db *sqlx.DB
+}
+
+func NewReactionsStore(db *sql.DB) *ReactionsStore {
+	return &ReactionsStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// Add records a reaction and reports whether it is new. Adding the same
+// reaction twice is a no-op.
+func (s *ReactionsStore) Add(ctx context.Context, postID int64, userID uuid.UUID, reactionType string) (bool, error) {
+	const query = `INSERT INTO post_reactions (post_id, user_id, type) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, postID, userID, reactionType)
+	if err != nil {
+		return false, err
+	}
+
+	added, err := res.RowsAffected()
+	return added > 0, err
+}
+
+func (s *ReactionsStore) Remove(ctx context.Context, postID int64, userID uuid.UUID, reactionType string) error {
+	const query = `DELETE FROM post_reactions WHERE post_id = $1 AND user_id = $2 AND type = $3;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, postID, userID, reactionType)
+	return err
+}
+
+// GetSummary returns the reaction counts of a post and the reaction types left by userID.
+func (s *ReactionsStore) GetSummary(ctx context.Context, postID int64, userID uuid.UUID) (ReactionCounts, []string, error) {
+	const query = `SELECT type, COUNT(*), BOOL_OR(user_id = $2)
+				   FROM post_reactions
+				   WHERE post_id = $1
+				   GROUP BY type;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, postID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	counts := ReactionCounts{}
+	mine := []string{}
+	for rows.Next() {
+		var (
+			reactionType string
+			count        int
+			reacted      bool
+		)
+		if err := rows.Scan(&reactionType, &count, &reacted); err != nil {
+			return nil, nil, err
+		}
+
+		counts[reactionType] = count
+		if reacted {
+			mine = append(mine, reactionType)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return counts, mine, nil
+}
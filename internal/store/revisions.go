@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+// PostRevision is a snapshot of a post as it was at Version. EditedBy is the
+// user whose edit replaced it and CreatedAt is when that edit happened.
+type PostRevision struct {
+	ID        int64          `json:"id" db:"id"`
+	PostID    int64          `json:"post_id" db:"post_id"`
+	Version   int            `json:"version" db:"version"`
+	Title     string         `json:"title" db:"title"`
+	Content   string         `json:"content" db:"content"`
+	Tags      pq.StringArray `json:"tags" db:"tags"`
+	EditedBy  *uuid.UUID     `json:"edited_by" db:"edited_by"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+}
+
+type RevisionsStore struct {
+	db *sqlx.DB
+}
+
+func NewRevisionsStore(db *sql.DB) *RevisionsStore {
+	return &RevisionsStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+func (s *RevisionsStore) ListByPostID(ctx context.Context, postID int64) ([]PostRevision, error) {
+	const query = `SELECT id, post_id, version, title, content, tags, edited_by, created_at
+				   FROM post_revisions
+				   WHERE post_id = $1
+				   ORDER BY version DESC;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	revisions := []PostRevision{}
+	if err := s.db.SelectContext(ctx, &revisions, query, postID); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (s *RevisionsStore) GetByVersion(ctx context.Context, postID int64, version int) (*PostRevision, error) {
+	const query = `SELECT id, post_id, version, title, content, tags, edited_by, created_at
+				   FROM post_revisions
+				   WHERE post_id = $1 AND version = $2;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var revision PostRevision
+	if err := s.db.GetContext(ctx, &revision, query, postID, version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &revision, nil
+}
+
+// createRevision snapshots the post at the given version before it is
+// overwritten. The row is locked so concurrent edits of the same version
+// fail with ErrEditConflict instead of racing.
+func createRevision(ctx context.Context, tx *sqlx.Tx, postID int64, version int, editorID uuid.UUID) error {
+	const lockQuery = `SELECT id FROM posts WHERE id = $1 AND version = $2 FOR UPDATE;`
+
+	const query = `INSERT INTO post_revisions (post_id, version, title, content, tags, edited_by)
+				   SELECT id, version, title, content, tags, $3
+				   FROM posts
+				   WHERE id = $1 AND version = $2;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var id int64
+	if err := tx.GetContext(ctx, &id, lockQuery, postID, version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrEditConflict
+		}
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, query, postID, version, editorID)
+	return err
+}
@@ -27,7 +27,7 @@ func (s *RolesStore) GetByName(ctx context.Context, roleName string) (*Role, err
 	const query = `SELECT * FROM roles WHERE name = $1;`
 
 	role := &Role{}
-	err := s.db.GetContext(ctx, &role, query, roleName)
+	err := s.db.GetContext(ctx, role, query, roleName)
 	if err != nil {
 		return nil, err
 	}
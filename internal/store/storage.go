@@ -3,29 +3,55 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"github.com/jmoiron/sqlx"
 	"time"
 )
 
 type Store struct {
-	Users     *UsersStore
-	Posts     *PostsStore
-	Comments  *CommentsStore
-	Followers *FollowerStore
-	Roles     *RolesStore
+	Users          *UsersStore
+	Posts          *PostsStore
+	Comments       *CommentsStore
+	Followers      *FollowerStore
+	Roles          *RolesStore
+	Reactions      *ReactionsStore
+	Bookmarks      *BookmarksStore
+	Revisions      *RevisionsStore
+	Tags           *TagsStore
+	Mentions       *MentionsStore
+	Notifications  *NotificationsStore
+	Conversations  *ConversationsStore
+	FollowRequests *FollowRequestsStore
+	Blocks         *BlocksStore
+	Mutes          *MutesStore
+	Attachments    *AttachmentsStore
 }
 
 var (
 	QueryTimeoutDuration = time.Second * 5
+
+	ErrNotFound     = errors.New("resource not found")
+	ErrEditConflict = errors.New("resource was modified by another request")
 )
 
 func NewStorage(db *sql.DB) *Store {
 	return &Store{
-		Posts:     NewPostsStore(db),
-		Users:     NewUsersStore(db),
-		Comments:  NewCommentsStore(db),
-		Followers: NewFollowerStore(db),
-		Roles:     NewRolesStore(db),
+		Posts:          NewPostsStore(db),
+		Users:          NewUsersStore(db),
+		Comments:       NewCommentsStore(db),
+		Followers:      NewFollowerStore(db),
+		Roles:          NewRolesStore(db),
+		Reactions:      NewReactionsStore(db),
+		Bookmarks:      NewBookmarksStore(db),
+		Revisions:      NewRevisionsStore(db),
+		Tags:           NewTagsStore(db),
+		Mentions:       NewMentionsStore(db),
+		Notifications:  NewNotificationsStore(db),
+		Conversations:  NewConversationsStore(db),
+		FollowRequests: NewFollowRequestsStore(db),
+		Blocks:         NewBlocksStore(db),
+		Mutes:          NewMutesStore(db),
+		Attachments:    NewAttachmentsStore(db),
 	}
 }
 
@@ -0,0 +1,206 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	MaxPostTags  = 10
+	MaxTagLength = 32
+)
+
+var (
+	ErrTooManyTags = fmt.Errorf("a post can have at most %d tags", MaxPostTags)
+	ErrInvalidTag  = fmt.Errorf("tags must be 1 to %d letters, digits, '-' or '_'", MaxTagLength)
+)
+
+type TagCount struct {
+	Tag       string `json:"tag" db:"tag"`
+	PostCount int    `json:"post_count" db:"post_count"`
+}
+
+// NormalizeTag lowercases a tag and strips the surrounding whitespace and a
+// leading '#'.
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+
+	if tag == "" || len([]rune(tag)) > MaxTagLength {
+		return "", ErrInvalidTag
+	}
+
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return "", ErrInvalidTag
+		}
+	}
+
+	return tag, nil
+}
+
+// NormalizeTags normalizes every tag and drops duplicates, keeping the order
+// in which the tags were first given.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+
+	for _, tag := range tags {
+		t, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+
+		normalized = append(normalized, t)
+	}
+
+	if len(normalized) > MaxPostTags {
+		return nil, ErrTooManyTags
+	}
+
+	return normalized, nil
+}
+
+type TagsStore struct {
+	db *sqlx.DB
+}
+
+func NewTagsStore(db *sql.DB) *TagsStore {
+	return &TagsStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// tagSearchCandidates caps how many tags matching a prefix are counted. The
+// candidates are the most used matches according to the post counts the tags
+// table keeps.
+const tagSearchCandidates = 200
+
+// Search suggests the most used tags starting with prefix. Matching tags are
+// looked up in the tags table first, by their overall post count, then their
+// visible posts are counted through the tags index of the posts.
+func (s *TagsStore) Search(ctx context.Context, prefix string, limit int) ([]TagCount, error) {
+	query := `
+SELECT t.tag, COUNT(*) AS post_count
+FROM (SELECT tag FROM tags WHERE tag LIKE $1 AND post_count > 0 ORDER BY post_count DESC, tag LIMIT $3) t
+JOIN posts p ON p.tags @> ARRAY[t.tag]::VARCHAR(100)[]
+WHERE ` + livePostClause + ` AND ` + publicAuthorClause + `
+GROUP BY t.tag
+ORDER BY post_count DESC, t.tag
+LIMIT $2;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	// The pattern is bound whole, so the planner sees a constant prefix and can
+	// use the text_pattern_ops index.
+	pattern := escapeLike(prefix) + "%"
+
+	tags := []TagCount{}
+	if err := s.db.SelectContext(ctx, &tags, query, pattern, limit, tagSearchCandidates); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+type TrendingTag struct {
+	Tag       string  `json:"tag" db:"tag"`
+	PostCount int     `json:"post_count" db:"post_count"`
+	Score     float64 `json:"score" db:"score"`
+}
+
+// TrendingWindow is a sliding window over which tag usage is counted. Each
+// post weighs less the older it is, halving every HalfLife.
+type TrendingWindow struct {
+	Name     string
+	Span     time.Duration
+	HalfLife time.Duration
+}
+
+var TrendingWindows = map[string]TrendingWindow{
+	"1h":  {Name: "1h", Span: time.Hour, HalfLife: 15 * time.Minute},
+	"24h": {Name: "24h", Span: 24 * time.Hour, HalfLife: 6 * time.Hour},
+	"7d":  {Name: "7d", Span: 7 * 24 * time.Hour, HalfLife: 48 * time.Hour},
+}
+
+// Trending ranks the tags of the live posts created within the window by their
+// decayed usage.
+func (s *TagsStore) Trending(ctx context.Context, window TrendingWindow, limit int) ([]TrendingTag, error) {
+	query := `
+SELECT tag,
+       COUNT(*) AS post_count,
+       SUM(EXP(-LN(2) * EXTRACT(EPOCH FROM (NOW() - p.created_at)) / $2)) AS score
+FROM posts p, unnest(p.tags) AS tag
+WHERE p.created_at >= NOW() - $1 * INTERVAL '1 second' AND ` + livePostClause + ` AND ` + publicAuthorClause + `
+GROUP BY tag
+ORDER BY score DESC, tag
+LIMIT $3;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tags := []TrendingTag{}
+	err := s.db.SelectContext(ctx, &tags, query, window.Span.Seconds(), window.HalfLife.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+type FollowedTag struct {
+	Tag       string    `json:"tag" db:"tag"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Follow subscribes the user to a tag. Following a tag twice is a no-op.
+func (s *TagsStore) Follow(ctx context.Context, userID uuid.UUID, tag string) error {
+	const query = `INSERT INTO tag_follows (user_id, tag) VALUES ($1, $2) ON CONFLICT DO NOTHING;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, tag)
+	return err
+}
+
+func (s *TagsStore) Unfollow(ctx context.Context, userID uuid.UUID, tag string) error {
+	const query = `DELETE FROM tag_follows WHERE user_id = $1 AND tag = $2;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, tag)
+	return err
+}
+
+func (s *TagsStore) ListFollowed(ctx context.Context, userID uuid.UUID) ([]FollowedTag, error) {
+	const query = `SELECT tag, created_at FROM tag_follows WHERE user_id = $1 ORDER BY tag;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tags := []FollowedTag{}
+	if err := s.db.SelectContext(ctx, &tags, query, userID); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
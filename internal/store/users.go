@@ -10,25 +10,40 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
+	"net"
 	"time"
 )
 
 var (
 	ErrDuplicateEmail    = errors.New("a user with this email already exists")
 	ErrDuplicateUsername = errors.New("a user with this username already exists")
+	ErrUsernameCooldown  = errors.New("the username was changed too recently")
+
+	ErrResetRequestedRecently = errors.New("a password reset was requested too recently")
 )
 
 type User struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	Username  string    `json:"username" db:"username"`
-	Email     string    `json:"email" db:"email"`
-	Password  string    `json:"password_hash" db:"password_hash"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	IsActive  bool      `json:"is_active" db:"is_active"`
-	RoleID    int64     `json:"role_id" db:"role_id"`
-	Role      Role      `json:"role" db:"level"`
-	RoleName  string    `json:"name" db:"name"`
+	ID                uuid.UUID  `json:"id" db:"id"`
+	Username          string     `json:"username" db:"username"`
+	Email             string     `json:"email" db:"email"`
+	Password          string     `json:"-" db:"password_hash"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	IsActive          bool       `json:"is_active" db:"is_active"`
+	IsPrivate         bool       `json:"is_private" db:"is_private"`
+	DisplayName       string     `json:"display_name" db:"display_name"`
+	Bio               string     `json:"bio" db:"bio"`
+	Location          string     `json:"location" db:"location"`
+	Website           string     `json:"website" db:"website"`
+	UsernameChangedAt *time.Time `json:"username_changed_at" db:"username_changed_at"`
+	AvatarID          *int64     `json:"avatar_id" db:"avatar_id"`
+	AvatarURL         string     `json:"avatar_url,omitempty" db:"-"`
+	AvatarStatus      string     `json:"-" db:"avatar_status"`
+	TokenVersion      int        `json:"-" db:"token_version"`
+	RoleID            int64      `json:"role_id" db:"role_id"`
+	Role              Role       `json:"role" db:"role"`
+	RoleName          string     `json:"name" db:"name"`
 }
 
 type UsersStore struct {
@@ -44,11 +59,10 @@ func NewUsersStore(db *sql.DB) *UsersStore {
 func (s *UsersStore) CreateUser(ctx context.Context, tx *sqlx.Tx, user *User) error {
 	const query = `INSERT INTO users (username, email, password_hash, role_id) VALUES ($1, $2, $3, (SELECT id FROM roles WHERE name = $4)) RETURNING *;`
 
-	bytes, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	passhash, err := hashPassword(user.Password)
 	if err != nil {
-		return fmt.Errorf("failed to hash the password: %w", err)
+		return err
 	}
-	passhash := base64.StdEncoding.EncodeToString(bytes)
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
@@ -62,7 +76,8 @@ func (s *UsersStore) CreateUser(ctx context.Context, tx *sqlx.Tx, user *User) er
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
 			return ErrDuplicateEmail
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"`:
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"`,
+			err.Error() == `pq: duplicate key value violates unique constraint "idx_users_username_lower"`:
 			return ErrDuplicateUsername
 		default:
 			return err
@@ -79,8 +94,20 @@ func (s *UsersStore) GetByID(ctx context.Context, userID uuid.UUID) (*User, erro
 			users.password_hash,
 			users.created_at,
 			users.is_active,
+			users.is_private,
+			users.display_name,
+			users.bio,
+			users.location,
+			users.website,
+			users.username_changed_at,
+			users.avatar_id,
+			COALESCE((SELECT a.status FROM attachments a WHERE a.id = users.avatar_id), '') AS avatar_status,
+			users.token_version,
 			users.role_id,
-			roles.name as name
+			roles.name as name,
+			roles.id as "role.id",
+			roles.name as "role.name",
+			roles.level as "role.level"
 		FROM users JOIN roles ON (users.role_id = roles.id) WHERE users.id = $1 AND users.is_active=true;`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -88,6 +115,9 @@ func (s *UsersStore) GetByID(ctx context.Context, userID uuid.UUID) (*User, erro
 
 	var user User
 	if err := s.db.GetContext(ctx, &user, query, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
 		return nil, fmt.Errorf("failed to get user from DB: %w", err)
 	}
 
@@ -102,6 +132,9 @@ func (s *UsersStore) GetByEmail(ctx context.Context, email string) (*User, error
 
 	var user User
 	if err := s.db.GetContext(ctx, &user, query, email); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
 		return nil, fmt.Errorf("failed to get user by email: %w", err)
 	}
 
@@ -237,3 +270,234 @@ func (s *UsersStore) deleteInvite(ctx context.Context, tx *sqlx.Tx, userID uuid.
 
 	return nil
 }
+
+// CreatePasswordReset stores the hashed token of a password reset for a user,
+// replacing any reset the user requested before. It fails with
+// ErrResetRequestedRecently when the user got a reset within the cooldown, or
+// when ip already requested ipLimit resets within it. Requests without an
+// address share one limit.
+func (s *UsersStore) CreatePasswordReset(ctx context.Context, userID uuid.UUID, token string, exp time.Duration, ip net.IP, cooldown time.Duration, ipLimit int) error {
+	return withTx(s.db, ctx, func(tx *sqlx.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// Lock the user so concurrent requests cannot both pass the cooldown.
+		if _, err := tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+			return err
+		}
+
+		const recentQuery = `
+SELECT
+    EXISTS (SELECT 1 FROM password_resets WHERE user_id = $1 AND created_at > $3),
+    (SELECT COUNT(*) FROM password_resets WHERE requested_ip IS NOT DISTINCT FROM $2 AND created_at > $3);
+`
+
+		var requestIP *string
+		if ip != nil {
+			addr := ip.String()
+			requestIP = &addr
+		}
+
+		var userRecent bool
+		var ipRecent int
+		since := time.Now().Add(-cooldown)
+		if err := tx.QueryRowContext(ctx, recentQuery, userID, requestIP, since).Scan(&userRecent, &ipRecent); err != nil {
+			return err
+		}
+
+		if userRecent || ipRecent >= ipLimit {
+			return ErrResetRequestedRecently
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM password_resets WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+
+		const query = `INSERT INTO password_resets (token, user_id, expiry, requested_ip) VALUES ($1, $2, $3, $4)`
+		_, err := tx.ExecContext(ctx, query, token, userID, time.Now().Add(exp), requestIP)
+		return err
+	})
+}
+
+// ResetPassword sets a new password for the user a reset token was issued to
+// and revokes the tokens issued before by bumping the token version. The reset
+// token can only be used once. It returns the ID of the user and the new token
+// version.
+func (s *UsersStore) ResetPassword(ctx context.Context, token, password string) (uuid.UUID, int, error) {
+	hash := sha256.Sum256([]byte(token))
+	hashToken := hex.EncodeToString(hash[:])
+
+	passhash, err := hashPassword(password)
+	if err != nil {
+		return uuid.Nil, 0, err
+	}
+
+	var userID uuid.UUID
+	var version int
+
+	err = withTx(s.db, ctx, func(tx *sqlx.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		const lookup = `SELECT user_id FROM password_resets WHERE token = $1 AND expiry > $2 FOR UPDATE;`
+		if err := tx.GetContext(ctx, &userID, lookup, hashToken, time.Now()); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		const query = `UPDATE users SET password_hash = $1, token_version = token_version + 1 WHERE id = $2 RETURNING token_version;`
+		if err := tx.QueryRowContext(ctx, query, passhash, userID).Scan(&version); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM password_resets WHERE user_id = $1`, userID)
+		return err
+	})
+
+	return userID, version, err
+}
+
+// PasswordMatches reports whether password is the user's password.
+func (u *User) PasswordMatches(password string) bool {
+	hash, err := base64.StdEncoding.DecodeString(u.Password)
+	if err != nil {
+		return false
+	}
+
+	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
+
+func hashPassword(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash the password: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(bytes), nil
+}
+
+// SetPrivacy switches a user's account between private and public. Going
+// public accepts every pending follow request.
+func (s *UsersStore) SetPrivacy(ctx context.Context, userID uuid.UUID, private bool) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET is_private = $2 WHERE id = $1;`, userID, private); err != nil {
+			return err
+		}
+
+		if private {
+			return nil
+		}
+
+		const acceptPending = `
+INSERT INTO followers (user_id, follower_id)
+SELECT target_id, requester_id FROM follow_requests WHERE target_id = $1
+ON CONFLICT DO NOTHING;
+`
+
+		if _, err := tx.ExecContext(ctx, acceptPending, userID); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM follow_requests WHERE target_id = $1;`, userID)
+		return err
+	})
+}
+
+// UpdateProfile saves the editable profile fields of a user. A new username is
+// only accepted once cooldown has passed since the previous change, and the old
+// one is kept in the username history so it can be redirected. On
+// ErrUsernameCooldown, user.UsernameChangedAt holds the time of the previous
+// change.
+func (s *UsersStore) UpdateProfile(ctx context.Context, user *User, cooldown time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sqlx.Tx) error {
+		var current struct {
+			Username  string     `db:"username"`
+			ChangedAt *time.Time `db:"username_changed_at"`
+		}
+
+		const lock = `SELECT username, username_changed_at FROM users WHERE id = $1 FOR UPDATE;`
+
+		if err := tx.GetContext(ctx, &current, lock, user.ID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		if user.Username != current.Username {
+			if current.ChangedAt != nil && time.Since(*current.ChangedAt) < cooldown {
+				user.UsernameChangedAt = current.ChangedAt
+				return ErrUsernameCooldown
+			}
+
+			const history = `INSERT INTO username_history (user_id, username) VALUES ($1, $2);`
+
+			if _, err := tx.ExecContext(ctx, history, user.ID, current.Username); err != nil {
+				return err
+			}
+		}
+
+		const query = `
+UPDATE users
+SET username = $2, display_name = $3, bio = $4, location = $5, website = $6,
+    username_changed_at = CASE WHEN username <> $2 THEN NOW() ELSE username_changed_at END
+WHERE id = $1
+RETURNING username_changed_at;
+`
+
+		err := tx.QueryRowContext(ctx, query, user.ID, user.Username, user.DisplayName, user.Bio, user.Location, user.Website).Scan(&user.UsernameChangedAt)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrDuplicateUsername
+			}
+			return err
+		}
+
+		return nil
+	})
+}
+
+// ResolveUsername finds the user currently holding a username, or else the
+// user who most recently gave it up. The second result reports whether the
+// username is a former one.
+func (s *UsersStore) ResolveUsername(ctx context.Context, username string) (uuid.UUID, bool, error) {
+	const query = `
+SELECT id, former FROM (
+    SELECT u.id, false AS former, NOW() AS changed_at
+    FROM users u
+    WHERE LOWER(u.username) = LOWER($1) AND u.is_active = true
+    UNION ALL
+    SELECT h.user_id, true AS former, h.changed_at
+    FROM username_history h
+    JOIN users u ON u.id = h.user_id AND u.is_active = true
+    WHERE LOWER(h.username) = LOWER($1)
+) candidates
+ORDER BY former, changed_at DESC
+LIMIT 1;
+`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var match struct {
+		ID     uuid.UUID `db:"id"`
+		Former bool      `db:"former"`
+	}
+
+	if err := s.db.GetContext(ctx, &match, query, username); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, false, ErrNotFound
+		}
+		return uuid.Nil, false, err
+	}
+
+	return match.ID, match.Former, nil
+}